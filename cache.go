@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An httpCache stores responses for URLs on disk, so that running the
+// same command over and over doesn't keep hitting the API
+type httpCache struct {
+	dir    string        // Where the responses are stored
+	ttl    time.Duration // How long responses are fresh for; 0 to go by their headers
+	reload bool          // Fetch every URL again, but still store the responses
+}
+
+// A cacheEntry is a response stored in the cache. The body is
+// stored decompressed, so Content-Encoding is never stored
+type cacheEntry struct {
+	Status int         `json:"status"`
+	Proto  string      `json:"proto"`
+	Header http.Header `json:"header"`
+	Stored time.Time   `json:"stored"`
+	Body   []byte      `json:"body"`
+}
+
+// defaultCacheDir returns the directory to cache responses in;
+// e.g. $XDG_CACHE_HOME/gron or ~/.cache/gron on Linux
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gron"), nil
+}
+
+// cacheableMethod says if responses to requests with a method can be cached
+func cacheableMethod(method string) bool {
+	return method == "GET" || method == "HEAD"
+}
+
+// key returns the cache key for a request. Anything that could change
+// the response is part of it, including credentials, so different
+// users never see each other's responses
+func (h *httpCache) key(req *http.Request, body []byte) string {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		switch name {
+		case "Accept-Encoding", "User-Agent", "If-None-Match", "If-Modified-Since":
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := sha256.New()
+	fmt.Fprintf(s, "%s %s\n", req.Method, req.URL)
+	for _, name := range names {
+		fmt.Fprintf(s, "%s: %s\n", name, strings.Join(req.Header[name], ", "))
+	}
+	s.Write([]byte("\n"))
+	s.Write(body)
+	return hex.EncodeToString(s.Sum(nil))
+}
+
+// path returns the file an entry is stored in
+func (h *httpCache) path(key string) string {
+	return filepath.Join(h.dir, key+".json")
+}
+
+// load returns the stored entry for a key. Entries that are
+// missing or can't be read are treated as not being there
+func (h *httpCache) load(key string) *cacheEntry {
+	if h.reload {
+		return nil
+	}
+	b, err := os.ReadFile(h.path(key))
+	if err != nil {
+		return nil
+	}
+	e := &cacheEntry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil
+	}
+	return e
+}
+
+// store writes an entry to the cache. It's written to a temporary file
+// first so that another gron can never read half an entry. Responses
+// can contain private data, so only the user can read them
+func (h *httpCache) store(key string, e *cacheEntry) error {
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(h.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), h.path(key))
+}
+
+// storable says if a response can be stored in the cache
+func storable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || !cacheableMethod(resp.Request.Method) {
+		return false
+	}
+	_, noStore := cacheControl(resp.Header)["no-store"]
+	return !noStore
+}
+
+// newCacheEntry makes an entry for a response with its decompressed body
+func newCacheEntry(resp *http.Response, body []byte, now time.Time) *cacheEntry {
+	h := resp.Header.Clone()
+	h.Del("Content-Encoding")
+	h.Del("Content-Length")
+	return &cacheEntry{
+		Status: resp.StatusCode,
+		Proto:  resp.Proto,
+		Header: h,
+		Stored: now,
+		Body:   body,
+	}
+}
+
+// cacheControl parses the directives in a Cache-Control header;
+// e.g. max-age=60, must-revalidate
+func cacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if name != "" {
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return cc
+}
+
+// fresh says if an entry can be used without asking the server if
+// it's changed. A ttl that isn't 0 overrides the response's headers
+func (e *cacheEntry) fresh(now time.Time, ttl time.Duration) bool {
+	age := now.Sub(e.Stored)
+	if ttl > 0 {
+		return age < ttl
+	}
+
+	cc := cacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		return err == nil && age < time.Duration(secs)*time.Second
+	}
+
+	// Expires is compared with the server's Date so
+	// that clocks that don't agree don't matter
+	expires, err := http.ParseTime(e.Header.Get("Expires"))
+	if err != nil {
+		return false
+	}
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.Stored
+	}
+	return age < expires.Sub(date)
+}
+
+// conditional returns the headers for asking the server if the
+// entry has changed, added to a copy of the given headers
+func (e *cacheEntry) conditional(h http.Header) http.Header {
+	h = h.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	if etag := e.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		h.Set("If-Modified-Since", lm)
+	}
+	return h
+}
+
+// revalidated updates an entry after a 304 Not Modified response,
+// which can come with new headers, like a later Expires
+func (e *cacheEntry) revalidated(h http.Header, now time.Time) {
+	for name, values := range h {
+		switch name {
+		case "Content-Encoding", "Content-Length":
+			continue
+		}
+		e.Header[name] = values
+	}
+	e.Stored = now
+}
+
+// response returns a response for the entry as if it had just been fetched
+func (e *cacheEntry) response(req *http.Request) *response {
+	return &response{
+		Response: &http.Response{
+			Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+			StatusCode: e.Status,
+			Proto:      e.Proto,
+			Header:     e.Header,
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+			Request:    req,
+		},
+		body:   bytes.NewReader(e.Body),
+		timing: &requestTiming{},
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchURLCache(t *testing.T) {
+	var requests, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer srv.Close()
+
+	c := requestConfig{
+		proxyURL: undefinedProxy,
+		noProxy:  undefinedProxy,
+		cache:    &httpCache{dir: t.TempDir()},
+	}
+
+	fetch := func(path string, c requestConfig) {
+		t.Helper()
+		r, err := getURL(srv.URL+path, c)
+		if err != nil {
+			t.Fatalf("want nil error for %s; have %s", path, err)
+		}
+		b, _ := io.ReadAll(r)
+		if want := `{"path": "` + path + `"}`; string(b) != want {
+			t.Errorf("want %s for %s; have %s", want, path, b)
+		}
+	}
+
+	cases := []struct {
+		path        string
+		c           requestConfig
+		requests    int
+		notModified int
+	}{
+		// Fresh for a minute, so only fetched once
+		{"/fresh", c, 1, 0},
+		// Revalidated with If-None-Match every time after the first
+		{"/etag", c, 3, 2},
+		// Never stored
+		{"/no-store", c, 3, 0},
+		// No validators or freshness, so fetched every time
+		{"/plain", c, 3, 0},
+	}
+
+	for _, test := range cases {
+		requests, notModified = 0, 0
+		for i := 0; i < 3; i++ {
+			fetch(test.path, test.c)
+		}
+		if requests != test.requests || notModified != test.notModified {
+			t.Errorf("want %d requests and %d not modified for %s; have %d and %d",
+				test.requests, test.notModified, test.path, requests, notModified)
+		}
+	}
+
+	// A ttl overrides the headers
+	requests = 0
+	ttl := c
+	ttl.cache = &httpCache{dir: c.cache.dir, ttl: time.Minute}
+	fetch("/plain", ttl)
+	fetch("/plain", ttl)
+	if requests != 0 {
+		t.Errorf("want 0 requests for /plain with a ttl; have %d", requests)
+	}
+
+	// Reloading fetches it again even though it's fresh
+	requests = 0
+	reload := c
+	reload.cache = &httpCache{dir: c.cache.dir, reload: true}
+	fetch("/fresh", reload)
+	if requests != 1 {
+		t.Errorf("want 1 request for /fresh when reloading; have %d", requests)
+	}
+
+	// Different headers mean a different response
+	requests = 0
+	other := c
+	other.header = http.Header{"Authorization": {"Bearer abc"}}
+	fetch("/fresh", other)
+	if requests != 1 {
+		t.Errorf("want 1 request for /fresh with different headers; have %d", requests)
+	}
+}
+
+func TestCacheEntryFresh(t *testing.T) {
+	stored := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := stored.Add(30 * time.Second)
+
+	cases := []struct {
+		header http.Header
+		ttl    time.Duration
+		want   bool
+	}{
+		{http.Header{}, 0, false},
+		{http.Header{}, time.Minute, true},
+		{http.Header{}, 10 * time.Second, false},
+		{http.Header{"Cache-Control": {"max-age=60"}}, 0, true},
+		{http.Header{"Cache-Control": {"public, max-age=10"}}, 0, false},
+		{http.Header{"Cache-Control": {"no-cache, max-age=60"}}, 0, false},
+		{http.Header{"Date": {"Wed, 01 Jan 2020 11:00:00 GMT"}, "Expires": {"Wed, 01 Jan 2020 11:01:00 GMT"}}, 0, true},
+		{http.Header{"Date": {"Wed, 01 Jan 2020 11:00:00 GMT"}, "Expires": {"Wed, 01 Jan 2020 11:00:10 GMT"}}, 0, false},
+		{http.Header{"Expires": {"0"}}, 0, false},
+	}
+
+	for _, test := range cases {
+		e := &cacheEntry{Header: test.header, Stored: stored}
+		if have := e.fresh(now, test.ttl); have != test.want {
+			t.Errorf("want %t for %v with ttl %s; have %t", test.want, test.header, test.ttl, have)
+		}
+	}
+}
@@ -8,7 +8,7 @@
 # Example: cat ./completions/gron.bash >> ~/.bashrc
 
 function _gron_completion {
-  local AVAILABLE_COMMANDS="--colorize --insecure --json --monochrome --no-sort --stream --ungron --values --version"
+  local AVAILABLE_COMMANDS="--cache --cache-ttl --cacert --cert --colorize --compress --concat --cursor-param --data --data-file --decode --duplicates --exclude --expand-json --flatten --follow --har --header --ignore-file --ignore-status --include --invalid-log --insecure --items --json --keep-going --key --lint --ndjson --monochrome --no-cache --netrc --netrc-file --max-array-gap --max-line-size --max-pages --next --no-sort --paginate --recursive --request --resolve --response --retries --retry-all --retry-wait --root --seq --skip-invalid --sni --sorted --sparse --split --strict --stream --timeout --tls-min --token-env --token-file --ungron --user --values --version --with-filename --workers"
   COMPREPLY=()
 
   local CURRENT_WORD=${COMP_WORDS[COMP_CWORD]}
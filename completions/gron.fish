@@ -8,9 +8,65 @@ complete -c gron -s u -l ungron     --description "Reverse the operation (turn a
 complete -c gron -s c -l colorize   --description "Colorize output (default on tty)"
 complete -c gron -s m -l monochrome --description "Monochrome (don't colorize output)"
 complete -c gron -s s -l stream     --description "Treat each line of input as a separate JSON object"
+complete -c gron      -l concat     --description "Like --stream, but allow any sequence of JSON values"
+complete -c gron      -l seq        --description "Read or write an RFC 7464 JSON text sequence"
+complete -c gron -s f -l follow     --description "Keep gronning records as they're added to a file" -r
+complete -c gron      -l duplicates --description "Output every occurrence of duplicate object keys"
+complete -c gron      -l lint       --description "Warn about duplicate object keys"
+complete -c gron      -l compress   --description "Compress the output" -x -a "gzip zstd xz"
+complete -c gron      -l workers    --description "The number of records to gron at the same time with --stream" -x
+complete -c gron      -l skip-invalid --description "With --stream, report lines that aren't valid JSON and carry on"
+complete -c gron      -l invalid-log --description "Write the lines skipped with --skip-invalid to this file" -r
+complete -c gron      -l ndjson     --description "When ungronning, write one line of JSON for each json[n]"
+complete -c gron      -l sorted     --description "With --ndjson or --seq, write each record as soon as it's complete"
 complete -c gron -s k -l insecure   --description "Disable certificate validation"
+complete -c gron -s X -l request    --description "The HTTP method to use for URLs" -x -a "GET POST PUT PATCH DELETE"
+complete -c gron -s H -l header     --description "Add a header to requests for URLs" -x
+complete -c gron      -l data       --description "Send a request body with URLs; @file reads it from a file" -x
+complete -c gron      -l data-file  --description "Send the contents of a file as the request body" -r
+complete -c gron      -l user       --description "Use basic auth for URLs, in the form user:password" -x
+complete -c gron      -l token-env  --description "Send a bearer token for URLs from this environment variable" -x
+complete -c gron      -l token-file --description "Send a bearer token for URLs from this file" -r
+complete -c gron -s n -l netrc      --description "Look up credentials for URLs by host in ~/.netrc"
+complete -c gron      -l netrc-file --description "Like --netrc, but use this file" -r
+complete -c gron      -l expand-json --description "Expand strings that hold JSON into statements"
+complete -c gron      -l decode     --description "Decode strings at paths matching a pattern (jwt, base64 or query)" -x
+complete -c gron      -l har        --description "Read HAR files, with JSON bodies decoded" -r
+complete -c gron      -l cacert     --description "Verify servers with the CAs in this PEM file" -r
+complete -c gron      -l cert       --description "Use the client certificate in this PEM file" -r
+complete -c gron      -l key        --description "The PEM file with the key for --cert" -r
+complete -c gron      -l tls-min    --description "The minimum TLS version" -x -a "1.0 1.1 1.2 1.3"
+complete -c gron      -l sni        --description "The server name to send and verify instead of the URL's host" -x
+complete -c gron      -l resolve    --description "Connect to an address instead of looking up a host (host:port:address)" -x
+complete -c gron      -l timeout    --description "How long fetching a URL may take, e.g. 30s" -x
+complete -c gron      -l retries    --description "How many times to retry fetching a URL" -x
+complete -c gron      -l retry-wait --description "How long to wait before the first retry, e.g. 1s" -x
+complete -c gron      -l retry-all  --description "Retry requests with any method, like POST"
+complete -c gron      -l ignore-status --description "Gron the body of non-2xx responses instead of failing"
+complete -c gron      -l cache      --description "Cache responses for URLs and use them while they're fresh"
+complete -c gron      -l cache-ttl  --description "How long cached responses are fresh for, e.g. 10m" -x
+complete -c gron      -l no-cache   --description "Fetch URLs again even if they're cached"
+complete -c gron      -l response   --description "Gron the status, headers and timing of URLs as well"
+complete -c gron      -l paginate   --description "Follow the rel=\"next\" Link headers of a URL and gron every page"
+complete -c gron      -l next       --description "The path to the next page's URL or cursor" -x
+complete -c gron      -l cursor-param --description "The query parameter to put the cursor in" -x
+complete -c gron      -l flatten    --description "Gron the items on every page as one array"
+complete -c gron      -l items      --description "The path to the array of items on each page" -x
+complete -c gron      -l max-pages  --description "The most pages to fetch with --paginate" -x
 complete -c gron -s j -l json       --description "Represent gron data as JSON stream"
 complete -c gron      -l no-sort    --description "Don't sort output (faster)"
+complete -c gron      -l keep-going --description "When ungronning, report every invalid statement"
+complete -c gron      -l strict     --description "When ungronning, reject statements that don't match the grammar"
+complete -c gron      -l sparse     --description "When ungronning, how to handle arrays with missing indexes" -x -a "null compact object"
+complete -c gron      -l max-array-gap --description "When ungronning, the most missing indexes an array may have" -x
+complete -c gron      -l max-line-size --description "The longest line or record in bytes (default no limit)" -x
+complete -c gron      -l with-filename --description "Put each input under its own key"
+complete -c gron      -l root       --description "The name of the root of the statements" -x
+complete -c gron      -l split      --description "When ungronning, write the JSON for each input to a separate file" -r
+complete -c gron -s r -l recursive  --description "Gron every JSON-like file in the named directories"
+complete -c gron      -l include    --description "Only gron files matching this glob when using --recursive" -x
+complete -c gron      -l exclude    --description "Skip files and directories matching this glob when using --recursive" -x
+complete -c gron      -l ignore-file --description "A .gitignore-style file of paths to skip when using --recursive" -r
 complete -c gron      -l version    --description "Print version information"
 
 # eof
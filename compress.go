@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// A compression is a compression format that gron can read, and
+// that it can write as well if newWriter isn't nil
+type compression struct {
+	name      string
+	magic     []byte // The bytes that compressed data starts with
+	newReader func(io.Reader) (io.Reader, error)
+	newWriter func(io.Writer) (io.WriteCloser, error)
+}
+
+// compressions are the formats that are detected and decompressed
+var compressions = []compression{
+	{
+		name:  "gzip",
+		magic: []byte{0x1f, 0x8b},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	},
+	{
+		name:  "zstd",
+		magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	},
+	{
+		name:  "bzip2",
+		magic: []byte("BZh"),
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+	},
+	{
+		name:  "xz",
+		magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		},
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return xz.NewWriter(w)
+		},
+	},
+}
+
+// compressionByName returns the compression format with the given name.
+// The x-gzip alias that's sometimes used for Content-Encoding is allowed
+func compressionByName(name string) (compression, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "x-gzip" {
+		name = "gzip"
+	}
+	for _, c := range compressions {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return compression{}, false
+}
+
+// A wrappedReader reads data made from an input, like the decompressed
+// data or converted HAR, keeping the input's name for error messages
+type wrappedReader struct {
+	io.Reader
+	src io.Reader
+}
+
+// Name returns the name of the original input
+func (d *wrappedReader) Name() string {
+	return inputName(d.src)
+}
+
+// Close closes the wrapping reader if it needs closing, and the original input
+func (d *wrappedReader) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	closeInput(d.src)
+	return nil
+}
+
+// decompress looks at the first few bytes of an input and, if they're
+// the magic bytes for one of the compression formats, returns a reader
+// for the decompressed data. Otherwise the data is returned as it is.
+// None of the magic bytes can start valid JSON, so no more than the
+// first byte is read before anything's been decided
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err != nil {
+		// Empty input is dealt with by whatever reads it
+		return &wrappedReader{br, r}, nil
+	}
+
+	for _, c := range compressions {
+		if first[0] != c.magic[0] {
+			continue
+		}
+		magic, _ := br.Peek(len(c.magic))
+		if !bytes.Equal(magic, c.magic) {
+			continue
+		}
+
+		dr, err := c.newReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s input: %s", c.name, err)
+		}
+		return &wrappedReader{dr, r}, nil
+	}
+
+	return &wrappedReader{br, r}, nil
+}
+
+// compressWriter returns a writer that compresses everything
+// written to it in the named format before writing it to w
+func compressWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	c, ok := compressionByName(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown compression format %q", name)
+	}
+	if c.newWriter == nil {
+		return nil, fmt.Errorf("writing %s output isn't supported", c.name)
+	}
+	return c.newWriter(w)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	want, err := os.ReadFile("testdata/one.json")
+	if err != nil {
+		t.Fatalf("failed to open want file: %s", err)
+	}
+
+	for _, c := range compressions {
+		var compressed []byte
+		if c.newWriter != nil {
+			buf := &bytes.Buffer{}
+			w, err := c.newWriter(buf)
+			if err != nil {
+				t.Fatalf("failed to create %s writer: %s", c.name, err)
+			}
+			w.Write(want)
+			w.Close()
+			compressed = buf.Bytes()
+		} else {
+			// There's nothing to write bzip2 with in the standard library
+			compressed, err = os.ReadFile("testdata/one.json.bz2")
+			if err != nil {
+				t.Fatalf("failed to open %s test file: %s", c.name, err)
+			}
+		}
+
+		r, err := decompress(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatalf("want nil error for %s; have %s", c.name, err)
+		}
+		have, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("want nil error reading %s; have %s", c.name, err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("want %s to decompress to %q; have %q", c.name, want, have)
+		}
+	}
+
+	// Anything else is left as it is
+	for _, in := range []string{"", `{"a": 1}`, `json.BZ = 1;`} {
+		r, err := decompress(strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("want nil error; have %s", err)
+		}
+		have, _ := io.ReadAll(r)
+		if string(have) != in {
+			t.Errorf("want %q to be left as it is; have %q", in, have)
+		}
+	}
+}
+
+func TestDecodeContent(t *testing.T) {
+	w, _ := compressWriter(&bytes.Buffer{}, "bzip2")
+	if w != nil {
+		t.Errorf("want no writer for bzip2")
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := compressWriter(buf, "gzip")
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	w.Write([]byte(`{}`))
+	w.Close()
+
+	r, err := decodeContent("x-gzip", buf)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	have, _ := io.ReadAll(r)
+	if string(have) != `{}` {
+		t.Errorf("want {}; have %q", have)
+	}
+
+	if _, err := decodeContent("br", buf); err == nil {
+		t.Errorf("want error for unsupported Content-Encoding; have nil")
+	}
+}
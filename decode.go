@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A valueDecoder decodes the string values at paths matching a pattern
+type valueDecoder struct {
+	name    string
+	pattern *regexp.Regexp
+	decode  func(string) (interface{}, bool)
+}
+
+// valueDecoders are the decoders given with --decode, in order
+type valueDecoders []valueDecoder
+
+// decoders are the decoders that can be used with --decode
+var decoders = map[string]func(string) (interface{}, bool){
+	"jwt":    decodeJWT,
+	"base64": decodeBase64,
+	"query":  decodeQuery,
+}
+
+// parseDecoder parses a --decode option in the form decoder=pattern;
+// e.g. jwt=json.auth.token or query=json.requests[*].body. In patterns
+// * matches one key or index, and ** matches any part of a path
+func parseDecoder(arg string) (valueDecoder, error) {
+	name, pattern, ok := strings.Cut(arg, "=")
+	if !ok || pattern == "" {
+		return valueDecoder{}, fmt.Errorf("invalid --decode %q; must be in the form decoder=pattern, e.g. jwt=json.token", arg)
+	}
+	decode, ok := decoders[name]
+	if !ok {
+		return valueDecoder{}, fmt.Errorf("invalid --decode %q; the decoder must be jwt, base64 or query", arg)
+	}
+	return valueDecoder{name, pathPattern(pattern), decode}, nil
+}
+
+// pathPattern turns a path pattern into a regular expression
+// that matches the whole of a path
+func pathPattern(pattern string) *regexp.Regexp {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			re.WriteString(`[^.\[\]]*`)
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String())
+}
+
+// decode is a stringExpander for the decoders given with --decode.
+// The first decoder whose pattern matches the path and that can decode
+// the value is used
+func (ds valueDecoders) decode(path statement, str string) (interface{}, string, bool) {
+	p := path.String()
+	for _, d := range ds {
+		if !d.pattern.MatchString(p) {
+			continue
+		}
+		if v, ok := d.decode(str); ok {
+			return v, "decoded " + d.name, true
+		}
+	}
+	return nil, "", false
+}
+
+// expanders returns the stringExpanders to use for the options
+// and the decoders given with --decode
+func expanders(opts int, ds valueDecoders) []stringExpander {
+	var e []stringExpander
+	if len(ds) > 0 {
+		e = append(e, ds.decode)
+	}
+	if opts&optExpandJSON > 0 {
+		e = append(e, expandEmbedded)
+	}
+	return e
+}
+
+// decodeBase64URL decodes base64url with or without padding,
+// which is how the parts of a JWT are encoded
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// decodeJWT decodes the header and claims of a JSON Web Token. The
+// signature is kept as it is because it can't be checked anyway
+func decodeJWT(s string) (interface{}, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	jwt := map[string]interface{}{"signature": parts[2]}
+	for i, name := range []string{"header", "claims"} {
+		b, err := decodeBase64URL(parts[i])
+		if err != nil {
+			return nil, false
+		}
+		v, ok := parseJSONObject(b)
+		if !ok {
+			return nil, false
+		}
+		jwt[name] = v
+	}
+	return jwt, true
+}
+
+// parseJSONObject parses some JSON if it's an object
+func parseJSONObject(b []byte) (map[string]interface{}, bool) {
+	v, ok := parseEmbedded(string(b))
+	m, isObject := v.(map[string]interface{})
+	return m, ok && isObject
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without
+// padding. JSON is decoded too; anything else has to be printable text
+func decodeBase64(s string) (interface{}, bool) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if s == "" {
+		return nil, false
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	if v, ok := parseEmbedded(string(b)); ok {
+		return v, true
+	}
+	if !printable(b) {
+		return nil, false
+	}
+	return string(b), true
+}
+
+// printable says if some bytes are UTF-8 text without control characters,
+// apart from whitespace. Random bytes very rarely are, so it's a good sign
+// that decoding something as base64 was the right thing to do
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeQuery decodes an application/x-www-form-urlencoded string, like
+// a query string. Keys with more than one value become arrays
+func decodeQuery(s string) (interface{}, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "?")
+	if !strings.Contains(s, "=") {
+		return nil, false
+	}
+
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, false
+	}
+
+	q := make(map[string]interface{}, len(values))
+	for k, vs := range values {
+		if len(vs) == 1 {
+			q[k] = vs[0]
+			continue
+		}
+		a := make([]interface{}, len(vs))
+		for i, v := range vs {
+			a[i] = v
+		}
+		q[k] = a
+	}
+	return q, true
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPathPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"json.token", "json.token", true},
+		{"json.token", "json.tokens", false},
+		{"json.*.token", "json.auth.token", true},
+		{"json.*.token", "json.a.b.token", false},
+		{"json.users[*].token", "json.users[12].token", true},
+		{"json.users[*].token", "json.users.x.token", false},
+		{`json[*].token`, `json["a b"].token`, true},
+		{"json.**.token", "json.a[0].b.token", true},
+		{"json.**", "json", false},
+		{"**", "json.anything[1]", true},
+	}
+
+	for _, c := range cases {
+		if have := pathPattern(c.pattern).MatchString(c.path); have != c.want {
+			t.Errorf("want %t for %s matching %s; have %t", c.want, c.pattern, c.path, have)
+		}
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	jwt := "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxMjMiLCJhZG1pbiI6dHJ1ZX0.c2ln"
+
+	cases := []struct {
+		decoder string
+		in      string
+		want    interface{}
+		ok      bool
+	}{
+		{"jwt", jwt, map[string]interface{}{
+			"header":    map[string]interface{}{"alg": "HS256"},
+			"claims":    map[string]interface{}{"sub": "123", "admin": true},
+			"signature": "c2ln",
+		}, true},
+		{"jwt", "a.b.c", nil, false},
+		{"jwt", "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxMjMiLCJhZG1pbiI6dHJ1ZX0", nil, false},
+		{"base64", "eyJhIjpbMSwyXX0=", map[string]interface{}{"a": []interface{}{json.Number("1"), json.Number("2")}}, true},
+		{"base64", "eyJhIjpbMSwyXX0", map[string]interface{}{"a": []interface{}{json.Number("1"), json.Number("2")}}, true},
+		{"base64", "aGVsbG8gd29ybGQ=", "hello world", true},
+		{"base64", "abcd", nil, false},
+		{"base64", "not base64!", nil, false},
+		{"query", "a=1&b=2&b=3&c=hello%20world", map[string]interface{}{
+			"a": "1",
+			"b": []interface{}{"2", "3"},
+			"c": "hello world",
+		}, true},
+		{"query", "?q=gron", map[string]interface{}{"q": "gron"}, true},
+		{"query", "just some text", nil, false},
+		{"query", "a=%zz", nil, false},
+	}
+
+	for _, c := range cases {
+		have, ok := decoders[c.decoder](c.in)
+		if ok != c.ok {
+			t.Errorf("want %t from %s decoding %q; have %t", c.ok, c.decoder, c.in, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(have, c.want) {
+			t.Errorf("want %#v from %s decoding %q; have %#v", c.want, c.decoder, c.in, have)
+		}
+	}
+}
+
+func TestGronDecode(t *testing.T) {
+	c := defaultActionConfig()
+	for _, arg := range []string{"base64=json.items[*].data", "query=json.**.form"} {
+		d, err := parseDecoder(arg)
+		if err != nil {
+			t.Fatalf("want nil error from parseDecoder(%s); have %s", arg, err)
+		}
+		c.decoders = append(c.decoders, d)
+	}
+
+	in := `{"items": [{"data": "eyJpZCI6MX0=", "form": "a=1"}], "data": "eyJpZCI6MX0=", "x": {"form": "b=2"}}`
+	want := []string{
+		`json = {};`,
+		`json.data = "eyJpZCI6MX0=";`,
+		`json.items = [];`,
+		`json.items[0] = {};`,
+		`json.items[0].data = {}; // decoded base64`,
+		`json.items[0].data.id = 1;`,
+		`json.items[0].form = {}; // decoded query`,
+		`json.items[0].form.a = "1";`,
+		`json.x = {};`,
+		`json.x.form = {}; // decoded query`,
+		`json.x.form.b = "2";`,
+	}
+
+	out := &bytes.Buffer{}
+	code, err := c.gron(strings.NewReader(in), out, optMonochrome)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error; have %d and %v", code, err)
+	}
+	if have := strings.TrimSpace(out.String()); have != strings.Join(want, "\n") {
+		t.Errorf("want:\n%s\nhave:\n%s", strings.Join(want, "\n"), have)
+	}
+
+	for _, arg := range []string{"jwt", "nope=json.a", "jwt="} {
+		if _, err := parseDecoder(arg); err == nil {
+			t.Errorf("want error from parseDecoder(%s); have nil", arg)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A duplicateKey is an object key that appears more than once in the
+// same object. encoding/json silently keeps the last one, so they have
+// to be found by walking the tokens of the JSON instead
+type duplicateKey struct {
+	path    statement // The path to the key; e.g. json.a
+	offsets []int64   // The byte offset of every occurrence of the key
+}
+
+// String returns a description of the duplicate key for warnings
+func (d duplicateKey) String() string {
+	offsets := make([]string, len(d.offsets))
+	for i, o := range d.offsets {
+		offsets[i] = fmt.Sprintf("%d", o)
+	}
+	return fmt.Sprintf("duplicate key %s at bytes %s", d.path, strings.Join(offsets, ", "))
+}
+
+// An occurrence is one of the times a key appears in an object, along
+// with the range of statements that were made for its value
+type occurrence struct {
+	offset     int64
+	start, end int
+}
+
+// A tokenWalker makes statements from the raw tokens of a JSON value
+type tokenWalker struct {
+	data []byte
+	d    *json.Decoder
+	ss   statements
+	dups []duplicateKey
+	keep bool // Keep every occurrence of duplicate keys
+}
+
+// statementsFromJSONTokens is like statementsFromJSON, but it walks the
+// tokens of the JSON so that it can find any duplicate keys. If keep is
+// true then there's a statement for every occurrence of a duplicate key,
+// flagged with a comment. Otherwise the last occurrence wins, just like
+// it would with statementsFromJSON
+func statementsFromJSONTokens(data []byte, prefix statement, keep bool) (statements, []duplicateKey, error) {
+	w := &tokenWalker{
+		data: data,
+		d:    json.NewDecoder(bytes.NewReader(data)),
+		ss:   make(statements, 0, 32),
+		keep: keep,
+	}
+	w.d.UseNumber()
+
+	err := w.value(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Statements for occurrences that lost out are nil
+	ss := w.ss[:0]
+	for _, s := range w.ss {
+		if s != nil {
+			ss = append(ss, s)
+		}
+	}
+	return ss, w.dups, nil
+}
+
+// value adds the statements for the next value in the input
+func (w *tokenWalker) value(prefix statement) error {
+	t, err := w.d.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := t.(json.Delim)
+	if !ok {
+		w.ss.addWithValue(prefix, valueTokenFromInterface(t))
+		return nil
+	}
+
+	switch delim {
+	case '[':
+		w.ss.addWithValue(prefix, token{"[]", typEmptyArray})
+		for i := 0; w.d.More(); i++ {
+			if err := w.value(prefix.withNumericKey(i)); err != nil {
+				return err
+			}
+		}
+	case '{':
+		w.ss.addWithValue(prefix, token{"{}", typEmptyObject})
+		if err := w.object(prefix); err != nil {
+			return err
+		}
+	}
+
+	// The closing ] or }
+	_, err = w.d.Token()
+	return err
+}
+
+// object adds the statements for the keys and values of an object,
+// having already read the opening brace
+func (w *tokenWalker) object(prefix statement) error {
+	var keys []string
+	seen := make(map[string][]occurrence)
+
+	for w.d.More() {
+		offset := w.keyOffset()
+		t, err := w.d.Token()
+		if err != nil {
+			return err
+		}
+		k, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("invalid object key %v", t)
+		}
+
+		path := prefix.withQuotedKey(k)
+		if validIdentifier(k) {
+			path = prefix.withBare(k)
+		}
+
+		start := len(w.ss)
+		if err := w.value(path); err != nil {
+			return err
+		}
+
+		if _, exists := seen[k]; !exists {
+			keys = append(keys, k)
+		}
+		seen[k] = append(seen[k], occurrence{offset, start, len(w.ss)})
+	}
+
+	for _, k := range keys {
+		occs := seen[k]
+		if len(occs) < 2 {
+			continue
+		}
+
+		d := duplicateKey{path: w.ss[occs[0].start][:equalsIndex(w.ss[occs[0].start])]}
+		for n, o := range occs {
+			d.offsets = append(d.offsets, o.offset)
+
+			switch {
+			case w.keep:
+				comment := fmt.Sprintf("// duplicate key, %d of %d", n+1, len(occs))
+				w.ss[o.start] = append(w.ss[o.start], token{comment, typComment})
+			case n < len(occs)-1:
+				for i := o.start; i < o.end; i++ {
+					w.ss[i] = nil
+				}
+			}
+		}
+		w.dups = append(w.dups, d)
+	}
+	return nil
+}
+
+// keyOffset returns the byte offset of the next key in an object. The
+// decoder's offset is the end of the previous token, so any whitespace
+// and commas after that need skipping
+func (w *tokenWalker) keyOffset() int64 {
+	offset := w.d.InputOffset()
+	for offset < int64(len(w.data)) && strings.IndexByte(" \t\r\n,", w.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
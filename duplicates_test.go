@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStatementsFromJSONTokens(t *testing.T) {
+	in := []byte(`{"a": 1, "b": {"x": 1, "x": [2]}, "a": {"c": 3}}`)
+
+	cases := []struct {
+		keep bool
+		want []string
+	}{
+		{false, []string{
+			`json = {};`,
+			`json.b = {};`,
+			`json.b.x = [];`,
+			`json.b.x[0] = 2;`,
+			`json.a = {};`,
+			`json.a.c = 3;`,
+		}},
+		{true, []string{
+			`json = {};`,
+			`json.a = 1; // duplicate key, 1 of 2`,
+			`json.b = {};`,
+			`json.b.x = 1; // duplicate key, 1 of 2`,
+			`json.b.x = []; // duplicate key, 2 of 2`,
+			`json.b.x[0] = 2;`,
+			`json.a = {}; // duplicate key, 2 of 2`,
+			`json.a.c = 3;`,
+		}},
+	}
+
+	for _, c := range cases {
+		ss, dups, err := statementsFromJSONTokens(in, statement{{"json", typBare}}, c.keep)
+		if err != nil {
+			t.Fatalf("want nil error; have %s", err)
+		}
+
+		have := make([]string, len(ss))
+		for i, s := range ss {
+			have[i] = s.String()
+		}
+		if !reflect.DeepEqual(have, c.want) {
+			t.Errorf("want %#v; have %#v", c.want, have)
+		}
+
+		// Inner objects are finished first
+		wantDups := []string{
+			"duplicate key json.b.x at bytes 15, 23",
+			"duplicate key json.a at bytes 1, 34",
+		}
+		haveDups := make([]string, len(dups))
+		for i, d := range dups {
+			haveDups[i] = d.String()
+		}
+		if !reflect.DeepEqual(haveDups, wantDups) {
+			t.Errorf("want %#v; have %#v", wantDups, haveDups)
+		}
+	}
+
+	// Without duplicates it's the same as statementsFromJSON
+	_, dups, err := statementsFromJSONTokens([]byte(`{"a": [{"b": 1}]}`), statement{{"json", typBare}}, true)
+	if err != nil || len(dups) != 0 {
+		t.Errorf("want no duplicates and nil error; have %v, %v", dups, err)
+	}
+}
+
+func TestDuplicatesRoundTrip(t *testing.T) {
+	// Ungronning the output of --duplicates has to give the same
+	// value for each key as encoding/json, where the last one wins
+	cases := []string{
+		`{"a":2,"a":1}`,
+		`{"a":"z","b":true,"a":"y","a":"x"}`,
+		`{"a":{"x":2},"a":{"x":1}}`,
+		`[{"b":10,"b":9}]`,
+	}
+
+	cfg := defaultActionConfig()
+	for _, in := range cases {
+		var want interface{}
+		if err := json.Unmarshal([]byte(in), &want); err != nil {
+			t.Fatalf("failed to decode %s: %s", in, err)
+		}
+
+		for _, opts := range []int{optMonochrome, optMonochrome | optJSON} {
+			statements := &bytes.Buffer{}
+			code, err := cfg.gron(strings.NewReader(in), statements, opts|optDuplicates)
+			if code != exitOK || err != nil {
+				t.Fatalf("want exitOK and nil error from gron; have %d and %v", code, err)
+			}
+			if !strings.Contains(statements.String(), "duplicate key, 2 of") {
+				t.Errorf("want duplicates flagged for %s with opts %d; have:\n%s", in, opts, statements)
+			}
+
+			out := &bytes.Buffer{}
+			code, err = cfg.ungron(statements, out, opts)
+			if code != exitOK || err != nil {
+				t.Fatalf("want exitOK and nil error from ungron; have %d and %v", code, err)
+			}
+
+			var have interface{}
+			if err := json.Unmarshal(out.Bytes(), &have); err != nil {
+				t.Fatalf("failed to decode ungron output %s: %s", out, err)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("want %#v for %s with opts %d; have %#v", want, in, opts, have)
+			}
+		}
+	}
+}
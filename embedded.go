@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// embeddedJSON is the comment on statements for string values that
+// were expanded because they held JSON; e.g. json.Message = {}; // embedded JSON
+// ungron turns the values of those statements back into strings
+const embeddedJSON = "embedded JSON"
+
+// A stringExpander turns the string value at a path into a value
+// to make statements for, and a comment saying what it did
+type stringExpander func(path statement, str string) (interface{}, string, bool)
+
+// expandStrings replaces string values with statements for whatever the
+// first expander that can do anything with them turns them into, marking
+// each one with a comment. The statements that are added are expanded
+// too, so JSON in a string in a string is expanded as well
+func (ss statements) expandStrings(expanders ...stringExpander) statements {
+	for i := 0; i < len(ss); i++ {
+		s := ss[i]
+		eq := equalsIndex(s)
+		if eq < 0 || eq+1 >= len(s) || s[eq+1].typ != typString {
+			continue
+		}
+
+		var str string
+		if err := json.Unmarshal([]byte(s[eq+1].text), &str); err != nil {
+			continue
+		}
+
+		for _, expand := range expanders {
+			v, what, ok := expand(s[:eq], str)
+			if !ok {
+				continue
+			}
+
+			var sub statements
+			sub.fill(s[:eq], v)
+
+			// Any comment that's already there, like the one for a
+			// duplicate key, is kept
+			comment := "// " + what
+			if last := s[len(s)-1]; last.typ == typComment {
+				comment = last.text + "; " + what
+			}
+			sub[0] = append(sub[0], token{comment, typComment})
+
+			ss[i] = sub[0]
+			ss = append(ss, sub[1:]...)
+			break
+		}
+	}
+	return ss
+}
+
+// expandEmbedded is a stringExpander for strings that hold
+// a JSON object or array, and nothing else
+func expandEmbedded(_ statement, str string) (interface{}, string, bool) {
+	v, ok := parseEmbedded(str)
+	return v, embeddedJSON, ok
+}
+
+// parseEmbedded parses a string if it holds a JSON object or array
+func parseEmbedded(str string) (interface{}, bool) {
+	str = strings.TrimSpace(str)
+	if !strings.HasPrefix(str, "{") && !strings.HasPrefix(str, "[") {
+		return nil, false
+	}
+
+	var v interface{}
+	d := json.NewDecoder(strings.NewReader(str))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil || d.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+// stripComments removes the comments from the end of statements so
+// that they can be ungronned, returning the paths of the statements
+// that were marked as embedded JSON
+func (ss statements) stripComments() []statement {
+	var paths []statement
+	for i, s := range ss {
+		if len(s) == 0 || s[len(s)-1].typ != typComment {
+			continue
+		}
+		if strings.HasSuffix(s[len(s)-1].text, embeddedJSON) {
+			if eq := equalsIndex(s); eq >= 0 {
+				paths = append(paths, s[:eq])
+			}
+		}
+		ss[i] = s[:len(s)-1]
+	}
+	return paths
+}
+
+// reembed turns the values at paths back into strings of JSON. The
+// deepest ones are done first, so that JSON in a string in a string
+// ends up as it was. Paths that aren't there any more, because the
+// statements for them were filtered out, are left alone
+func reembed(v interface{}, paths []statement) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+
+	for _, path := range paths {
+		var keys []interface{}
+		for _, t := range path {
+			switch t.typ {
+			case typBare:
+				keys = append(keys, t.text)
+			case typQuotedKey:
+				var k string
+				if err := json.Unmarshal([]byte(t.text), &k); err != nil {
+					return
+				}
+				keys = append(keys, k)
+			case typNumericKey:
+				k, err := strconv.Atoi(t.text)
+				if err != nil {
+					return
+				}
+				keys = append(keys, k)
+			}
+		}
+		setEmbedded(v, keys)
+	}
+}
+
+// setEmbedded finds the value at a list of keys and replaces it
+// with a string of its JSON
+func setEmbedded(v interface{}, keys []interface{}) {
+	if len(keys) == 0 {
+		return
+	}
+
+	var target interface{}
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		k, ok := keys[0].(string)
+		if !ok {
+			return
+		}
+		target, ok = vv[k]
+		if !ok {
+			return
+		}
+		if len(keys) == 1 {
+			if s, ok := encodeEmbedded(target); ok {
+				vv[k] = s
+			}
+			return
+		}
+
+	case []interface{}:
+		k, ok := keys[0].(int)
+		if !ok || k >= len(vv) {
+			return
+		}
+		target = vv[k]
+		if len(keys) == 1 {
+			if s, ok := encodeEmbedded(target); ok {
+				vv[k] = s
+			}
+			return
+		}
+
+	default:
+		return
+	}
+	setEmbedded(target, keys[1:])
+}
+
+// encodeEmbedded encodes an object or array as compact JSON. It can't
+// know how the string was formatted to start with, so the keys of
+// objects are sorted and any escapes that aren't needed are dropped.
+// The encoding/json default of escaping HTML isn't used because that's
+// very unlikely to be how the string was to start with
+func encodeEmbedded(v interface{}) (string, bool) {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return "", false
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExpandEmbedded(t *testing.T) {
+	in := `{"body": "{\"Message\": \"[1, {\\\"a\\\": true}]\"}", "plain": "{nope", "num": "123"}`
+	want := []string{
+		`json = {};`,
+		`json.body = {}; // embedded JSON`,
+		`json.body.Message = []; // embedded JSON`,
+		`json.body.Message[0] = 1;`,
+		`json.body.Message[1] = {};`,
+		`json.body.Message[1].a = true;`,
+		`json.num = "123";`,
+		`json.plain = "{nope";`,
+	}
+
+	out := &bytes.Buffer{}
+	code, err := defaultActionConfig().gron(strings.NewReader(in), out, optMonochrome|optExpandJSON)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error; have %d and %v", code, err)
+	}
+	if have := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want:\n%s\nhave:\n%s", strings.Join(want, "\n"), out)
+	}
+}
+
+func TestEmbeddedRoundTrip(t *testing.T) {
+	cases := []string{
+		`{"body":"{\"Message\":\"{\\\"id\\\":12345678901234567890,\\\"tags\\\":[\\\"<a>\\\"]}\",\"Type\":\"Notification\"}"}`,
+		`["[1,2]",{"a":"{}"}]`,
+		`{"a":"[\"{\\\"b\\\":null}\"]","b":"not json"}`,
+	}
+
+	// The options used to gron, and those used to ungron the result
+	modes := []struct {
+		gron   int
+		ungron []int
+	}{
+		{optMonochrome, []int{optMonochrome, optMonochrome | optStrict}},
+		{optMonochrome | optJSON, []int{optMonochrome | optJSON}},
+	}
+
+	for _, in := range cases {
+		for _, mode := range modes {
+			statements := &bytes.Buffer{}
+			code, err := defaultActionConfig().gron(strings.NewReader(in), statements, mode.gron|optExpandJSON)
+			if code != exitOK || err != nil {
+				t.Fatalf("want exitOK and nil error from gron; have %d and %v", code, err)
+			}
+
+			for _, opts := range mode.ungron {
+				out := &bytes.Buffer{}
+				code, err = defaultActionConfig().ungron(bytes.NewReader(statements.Bytes()), out, opts)
+				if code != exitOK || err != nil {
+					t.Fatalf("want exitOK and nil error from ungron; have %d and %v", code, err)
+				}
+
+				compact := &bytes.Buffer{}
+				if err := json.Compact(compact, out.Bytes()); err != nil {
+					t.Fatalf("failed to compact ungron output: %s", err)
+				}
+				if compact.String() != in {
+					t.Errorf("want %s with opts %d; have %s", in, opts, compact)
+				}
+			}
+		}
+	}
+}
+
+func TestEmbeddedRoundTripNormalised(t *testing.T) {
+	// The data is the same, but the embedded JSON is written
+	// back compact, with sorted keys and no needless escapes
+	in := `{"a":"{\"b\": 1, \"a\": [1, 2.50], \"c\": \"\\u00e9\\/\"}"}`
+	want := `{"a":"{\"a\":[1,2.50],\"b\":1,\"c\":\"é/\"}"}`
+
+	statements := &bytes.Buffer{}
+	code, err := defaultActionConfig().gron(strings.NewReader(in), statements, optMonochrome|optExpandJSON)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error from gron; have %d and %v", code, err)
+	}
+
+	out := &bytes.Buffer{}
+	code, err = defaultActionConfig().ungron(statements, out, optMonochrome)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error from ungron; have %d and %v", code, err)
+	}
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, out.Bytes()); err != nil {
+		t.Fatalf("failed to compact ungron output: %s", err)
+	}
+	if compact.String() != want {
+		t.Errorf("want %s; have %s", want, compact)
+	}
+}
+
+func TestEmbeddedWithoutMarker(t *testing.T) {
+	// Statements for embedded JSON that have lost their marker, e.g.
+	// by grepping for part of them, are ungronned as they are
+	in := "json.body.Message.id = 1;\n"
+	out := &bytes.Buffer{}
+	code, err := defaultActionConfig().ungron(strings.NewReader(in), out, optMonochrome)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error; have %d and %v", code, err)
+	}
+	compact := &bytes.Buffer{}
+	json.Compact(compact, out.Bytes())
+	if want := `{"body":{"Message":{"id":1}}}`; compact.String() != want {
+		t.Errorf("want %s; have %s", want, compact)
+	}
+}
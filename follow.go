@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// followInterval is how often a followed file is checked for
+// new data once everything in it has been read
+var followInterval = 250 * time.Millisecond
+
+// A followReader reads a file like tail -f does; when it gets to the
+// end of the file it waits for more data to be written rather than
+// returning io.EOF. If the file is truncated it starts again from the
+// beginning, and if the file is replaced (e.g. by log rotation) it
+// finishes reading the old file and then carries on with the new one
+type followReader struct {
+	name    string
+	f       *os.File
+	info    os.FileInfo // The file that's open, to spot rotation
+	pos     int64       // How far into the open file has been read
+	rotated bool        // The file has been replaced; switch at the next EOF
+}
+
+// newFollowReader opens the named file to be followed
+func newFollowReader(name string) (*followReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &followReader{name: name, f: f, info: info}, nil
+}
+
+// Name returns the name of the file being followed
+func (fr *followReader) Name() string {
+	return fr.name
+}
+
+// Close closes the file being followed
+func (fr *followReader) Close() error {
+	return fr.f.Close()
+}
+
+// Read reads from the file, waiting for more to be
+// written whenever the end of the file is reached
+func (fr *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := fr.f.Read(p)
+		fr.pos += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if fr.rotated {
+			// Everything written to the old file has been
+			// read now, so it's safe to move on to the new one
+			fr.reopen()
+			continue
+		}
+
+		if !fr.changed() {
+			time.Sleep(followInterval)
+		}
+	}
+}
+
+// changed checks whether the file has grown, been truncated or been
+// replaced since it was last read from. Truncation is dealt with
+// straight away by going back to the start of the file
+func (fr *followReader) changed() bool {
+	info, err := os.Stat(fr.name)
+	if err != nil {
+		// The file might be part-way through being rotated,
+		// so wait for it to reappear
+		return false
+	}
+
+	if !os.SameFile(info, fr.info) {
+		// The old file is read once more before switching
+		// in case anything was written to it in the meantime
+		fr.rotated = true
+		return true
+	}
+
+	if info.Size() < fr.pos {
+		if _, err := fr.f.Seek(0, io.SeekStart); err == nil {
+			fr.pos = 0
+		}
+		return true
+	}
+
+	return info.Size() > fr.pos
+}
+
+// reopen switches to the file that has replaced the one being followed
+func (fr *followReader) reopen() {
+	fr.rotated = false
+
+	f, err := os.Open(fr.name)
+	if err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	fr.f.Close()
+	fr.f = f
+	fr.info = info
+	fr.pos = 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readFollowed reads from a followReader until it has read want,
+// failing the test if that takes too long
+func readFollowed(t *testing.T, fr *followReader, want string) {
+	t.Helper()
+
+	done := make(chan string)
+	go func() {
+		buf := make([]byte, len(want))
+		n := 0
+		for n < len(want) {
+			m, err := fr.Read(buf[n:])
+			if err != nil {
+				break
+			}
+			n += m
+		}
+		done <- string(buf[:n])
+	}()
+
+	select {
+	case have := <-done:
+		if have != want {
+			t.Errorf("want %q; have %q", want, have)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+func TestFollowReader(t *testing.T) {
+	old := followInterval
+	followInterval = 10 * time.Millisecond
+	defer func() { followInterval = old }()
+
+	name := filepath.Join(t.TempDir(), "app.log")
+	write := func(flag int, s string) {
+		f, err := os.OpenFile(name, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatalf("failed to open test file: %s", err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatalf("failed to write test file: %s", err)
+		}
+	}
+
+	write(os.O_TRUNC, "one\n")
+	fr, err := newFollowReader(name)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	defer fr.Close()
+	readFollowed(t, fr, "one\n")
+
+	// Appended
+	write(os.O_APPEND, "two\n")
+	readFollowed(t, fr, "two\n")
+
+	// Truncated
+	write(os.O_TRUNC, "3\n")
+	readFollowed(t, fr, "3\n")
+
+	// Rotated
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatalf("failed to rotate test file: %s", err)
+	}
+	write(os.O_TRUNC, "four\n")
+	readFollowed(t, fr, "four\n")
+}
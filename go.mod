@@ -4,9 +4,11 @@ go 1.24
 
 require (
 	github.com/fatih/color v1.18.0
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-colorable v0.1.14
 	github.com/nwidger/jsoncolor v0.3.2
 	github.com/pkg/errors v0.9.1
+	github.com/ulikunitz/xz v0.5.15
 )
 
 require (
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readHAR reads a HAR file, as exported from a browser's devtools, and
+// returns the JSON for its log; so the entries are json.entries[n].
+// Request and response bodies that are JSON are decoded so that they
+// can be gronned too, rather than being left as one long string
+func readHAR(r io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var har struct {
+		Log map[string]interface{} `json:"log"`
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&har); err != nil {
+		return nil, fmt.Errorf("failed to decode HAR: %s", err)
+	}
+	if har.Log == nil {
+		return nil, fmt.Errorf("failed to decode HAR: there's no log")
+	}
+
+	entries, _ := har.Log["entries"].([]interface{})
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if req, ok := entry["request"].(map[string]interface{}); ok {
+			decodeHARBody(req["postData"])
+		}
+		if resp, ok := entry["response"].(map[string]interface{}); ok {
+			decodeHARBody(resp["content"])
+		}
+	}
+
+	out, err := json.Marshal(har.Log)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedReader{bytes.NewReader(out), r}, nil
+}
+
+// decodeHARBody replaces the text of a request's postData or a response's
+// content with its JSON, under the json key, if it is JSON. Response
+// content can be base64 encoded, which the encoding key says
+func decodeHARBody(b interface{}) {
+	body, ok := b.(map[string]interface{})
+	if !ok {
+		return
+	}
+	text, ok := body["text"].(string)
+	if !ok {
+		return
+	}
+
+	raw := []byte(text)
+	if enc, _ := body["encoding"].(string); enc == "base64" {
+		var err error
+		raw, err = base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return
+		}
+	}
+
+	// Anything that says it's JSON is tried, and so is anything that
+	// looks like an object or array, because mime types can't be trusted
+	mime, _ := body["mimeType"].(string)
+	raw = bytes.TrimSpace(raw)
+	if !strings.Contains(strings.ToLower(mime), "json") && !bytes.HasPrefix(raw, []byte("{")) && !bytes.HasPrefix(raw, []byte("[")) {
+		return
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil || d.More() {
+		return
+	}
+
+	body["json"] = v
+	delete(body, "text")
+	delete(body, "encoding")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadHAR(t *testing.T) {
+	in, err := os.Open("testdata/devtools.har")
+	if err != nil {
+		t.Fatalf("failed to open input file: %s", err)
+	}
+	defer in.Close()
+
+	want, err := os.ReadFile("testdata/devtools.gron")
+	if err != nil {
+		t.Fatalf("failed to open want file: %s", err)
+	}
+
+	r, err := readHAR(in)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	out := &bytes.Buffer{}
+	code, err := defaultActionConfig().gron(r, out, optMonochrome)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error; have %d and %v", code, err)
+	}
+	if !bytes.Equal(want, out.Bytes()) {
+		t.Logf("want: %s", want)
+		t.Logf("have: %s", out.Bytes())
+		t.Errorf("gronned testdata/devtools.har does not match testdata/devtools.gron")
+	}
+}
+
+func TestReadHARInvalid(t *testing.T) {
+	for _, in := range []string{`not json`, `{"entries": []}`} {
+		_, err := readHAR(strings.NewReader(in))
+		if err == nil {
+			t.Errorf("want error for %s; have nil", in)
+		}
+	}
+}
+
+func TestDecodeHARBody(t *testing.T) {
+	cases := []struct {
+		body     map[string]interface{}
+		wantJSON bool
+	}{
+		{map[string]interface{}{"mimeType": "application/json", "text": `{"a": 1}`}, true},
+		{map[string]interface{}{"mimeType": "text/plain", "text": `[1, 2]`}, true},
+		{map[string]interface{}{"mimeType": "application/json", "encoding": "base64", "text": "eyJhIjogMX0="}, true},
+		{map[string]interface{}{"mimeType": "text/plain", "text": `123`}, false},
+		{map[string]interface{}{"mimeType": "application/json", "text": `{"a": 1} {"b": 2}`}, false},
+		{map[string]interface{}{"mimeType": "application/json", "encoding": "base64", "text": "!!!"}, false},
+		{map[string]interface{}{"mimeType": "application/json"}, false},
+	}
+
+	for _, c := range cases {
+		text := c.body["text"]
+		decodeHARBody(c.body)
+		_, haveJSON := c.body["json"]
+		if haveJSON != c.wantJSON {
+			t.Errorf("want decoded %t for %v; have %t", c.wantJSON, text, haveJSON)
+		}
+		if _, haveText := c.body["text"]; haveText == haveJSON && text != nil {
+			t.Errorf("want text to be replaced only when it's decoded for %v", text)
+		}
+	}
+}
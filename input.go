@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An input is a file, URL or stdin that's been named on the
+// command line or found by walking a directory
+type input struct {
+	name   string // The name used in the statements for the input
+	path   string // The path or URL to open
+	stream bool   // The input has one JSON value per line
+}
+
+// namedInputs returns an input for each name, where the
+// name and the path to open are the same thing
+func namedInputs(names []string) []input {
+	inputs := make([]input, len(names))
+	for i, name := range names {
+		inputs[i] = input{name: name, path: name}
+	}
+	return inputs
+}
+
+// inputErrors is a list of errors from inputs that couldn't be
+// gronned, so that they can all be reported once every other
+// input has been dealt with
+type inputErrors []error
+
+func (es inputErrors) Error() string {
+	out := make([]string, 0, len(es)+1)
+	for _, e := range es {
+		out = append(out, e.Error())
+	}
+	if len(es) == 1 {
+		out = append(out, "1 input could not be gronned")
+	} else {
+		out = append(out, fmt.Sprintf("%d inputs could not be gronned", len(es)))
+	}
+	return strings.Join(out, "\n")
+}
+
+// an openFn opens a named input; a file, URL or '-' for stdin. It
+// returns an exit code and an error on failure
+type openFn func(name string) (io.Reader, int, error)
+
+// a prefixedActionFn is like an actionFn, but every statement
+// it outputs starts with the provided prefix
+type prefixedActionFn func(io.Reader, io.Writer, int, statement) (int, error)
+
+// hasGlobMeta returns true if a string contains any of the
+// special characters used in glob patterns
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[`)
+}
+
+// expandInputs expands any glob patterns in the input names given on the
+// command line. Shells normally do this for us, but not when the pattern
+// is quoted or on Windows. URLs and names that don't exist as they are
+// aren't treated as patterns, so a file called 'what?.json' still works
+func expandInputs(args []string) ([]string, error) {
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "-" || validURL(arg) || !hasGlobMeta(arg) {
+			names = append(names, arg)
+			continue
+		}
+
+		if _, err := os.Stat(arg); err == nil {
+			names = append(names, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", arg)
+		}
+		names = append(names, matches...)
+	}
+	return names, nil
+}
+
+// closeInput closes an input if it needs closing
+func closeInput(r io.Reader) {
+	if r == os.Stdin {
+		return
+	}
+	if c, ok := r.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// inputPrefix returns the prefix for statements from the named
+// input when there's more than one; e.g. json["users.json"]
+func inputPrefix(root, name string) statement {
+	return statement{{root, typBare}}.withQuotedKey(name)
+}
+
+// gronInputs grons each of the inputs in turn using fn, or streamFn for
+// inputs with one value per line, with the statements for each one under
+// its own key of the root object. E.g. json["users.json"].name = "Tom";
+// Inputs that can't be opened or gronned are skipped, and all of the
+// errors are returned together at the end. The exit code is exitPartial
+// if any input was gronned, or the code for the first error if none were
+func gronInputs(inputs []input, w io.Writer, opts int, root string, open openFn, fn, streamFn prefixedActionFn) (int, error) {
+	conv := statementToColorString
+	if opts&optMonochrome > 0 {
+		conv = statementToString
+	}
+
+	// The root is an object with a key for each input
+	var top statements
+	top.addWithValue(statement{{root, typBare}}, token{"{}", typEmptyObject})
+	if opts&optJSON > 0 {
+		j, err := top[0].jsonify()
+		if err != nil {
+			return exitFormStatements, fmt.Errorf("failed to form statements: %s", err)
+		}
+		top[0] = j
+	}
+	fmt.Fprintln(w, conv(top[0]))
+
+	var errs inputErrors
+	exitCode := exitOK
+	gronned := 0
+	for _, in := range inputs {
+		r, code, err := open(in.path)
+		if err == nil {
+			g := fn
+			if in.stream {
+				g = streamFn
+			}
+			code, err = g(r, w, opts, inputPrefix(root, in.name))
+			closeInput(r)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", in.path, err))
+			if exitCode == exitOK {
+				exitCode = code
+			}
+			continue
+		}
+		gronned++
+	}
+
+	if len(errs) > 0 {
+		if gronned > 0 {
+			exitCode = exitPartial
+		}
+		return exitCode, errs
+	}
+	return exitOK, nil
+}
+
+// concatInputs opens all of the named inputs and returns a reader
+// that reads them one after the other
+func concatInputs(names []string, open openFn) (io.Reader, int, error) {
+	readers := make([]io.Reader, 0, len(names))
+	for _, name := range names {
+		r, code, err := open(name)
+		if err != nil {
+			return nil, code, err
+		}
+		readers = append(readers, r)
+	}
+
+	if len(readers) == 1 {
+		return readers[0], exitOK, nil
+	}
+	return newConcatReader(readers), exitOK, nil
+}
+
+// A concatReader reads several inputs one after the other, like an
+// io.MultiReader, but it keeps track of the line each input starts on
+// so that a line of the whole lot can be traced back to its input. A
+// newline is added after any input that doesn't end with one so that
+// its last line isn't joined on to the first line of the next input
+type concatReader struct {
+	readers []io.Reader
+	starts  []int // The line of the whole input that each input starts on
+	cur     int   // The index of the input being read
+	lines   int   // The number of newlines read so far
+	last    byte  // The last byte read from the current input
+}
+
+// newConcatReader returns a concatReader for the inputs
+func newConcatReader(readers []io.Reader) *concatReader {
+	return &concatReader{
+		readers: readers,
+		starts:  []int{1},
+	}
+}
+
+func (c *concatReader) Read(p []byte) (int, error) {
+	for c.cur < len(c.readers) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+
+		n, err := c.readers[c.cur].Read(p)
+		if n > 0 {
+			c.lines += bytes.Count(p[:n], []byte("\n"))
+			c.last = p[n-1]
+			return n, nil
+		}
+		if err != io.EOF {
+			return 0, err
+		}
+
+		// The end of an input; the next one starts on a new line
+		if c.last != '\n' && c.last != 0 && c.cur < len(c.readers)-1 {
+			p[0] = '\n'
+			c.lines++
+			c.last = 0
+			c.next()
+			return 1, nil
+		}
+		c.last = 0
+		c.next()
+	}
+	return 0, io.EOF
+}
+
+// next moves on to the next input
+func (c *concatReader) next() {
+	c.cur++
+	if c.cur < len(c.readers) {
+		c.starts = append(c.starts, c.lines+1)
+	}
+}
+
+// locate returns the name of the input that a line of the whole input
+// came from, and the line number within that input. It only works for
+// lines that have already been read
+func (c *concatReader) locate(line int) (string, int) {
+	i := sort.SearchInts(c.starts, line+1) - 1
+	if i < 0 {
+		i = 0
+	}
+	return inputName(c.readers[i]), line - c.starts[i] + 1
+}
+
+// Close closes all of the inputs
+func (c *concatReader) Close() error {
+	for _, r := range c.readers {
+		closeInput(r)
+	}
+	return nil
+}
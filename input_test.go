@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.json", "b.json", "c.txt"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644)
+		if err != nil {
+			t.Fatalf("failed to write test file: %s", err)
+		}
+	}
+
+	have, err := expandInputs([]string{
+		"-",
+		filepath.Join(dir, "*.json"),
+		"https://example.com/*.json",
+		filepath.Join(dir, "c.txt"),
+	})
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+
+	want := []string{
+		"-",
+		filepath.Join(dir, "a.json"),
+		filepath.Join(dir, "b.json"),
+		"https://example.com/*.json",
+		filepath.Join(dir, "c.txt"),
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v; have %v", want, have)
+	}
+
+	_, err = expandInputs([]string{filepath.Join(dir, "*.yaml")})
+	if err == nil {
+		t.Errorf("want non-nil error for pattern with no matches; have nil")
+	}
+}
+
+// stringOpener returns an openFn that opens strings from a map
+func stringOpener(inputs map[string]string) openFn {
+	return func(name string) (io.Reader, int, error) {
+		in, ok := inputs[name]
+		if !ok {
+			return nil, exitOpenFile, os.ErrNotExist
+		}
+		return strings.NewReader(in), exitOK, nil
+	}
+}
+
+func TestGronInputs(t *testing.T) {
+	open := stringOpener(map[string]string{
+		"users.json": `{"name": "Tom"}`,
+		"b.json":     `[1]`,
+	})
+
+	out := &bytes.Buffer{}
+	cfg := defaultActionConfig()
+	code, err := gronInputs(namedInputs([]string{"users.json", "b.json"}), out, optMonochrome, "json", open, cfg.gronWithPrefix, cfg.gronStreamWithPrefix)
+	if code != exitOK {
+		t.Errorf("want exitOK; have %d", code)
+	}
+	if err != nil {
+		t.Errorf("want nil error; have %s", err)
+	}
+
+	want := strings.Join([]string{
+		`json = {};`,
+		`json["users.json"] = {};`,
+		`json["users.json"].name = "Tom";`,
+		`json["b.json"] = [];`,
+		`json["b.json"][0] = 1;`,
+		``,
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("want:\n%s\nhave:\n%s", want, out.String())
+	}
+
+	// Bad inputs are skipped, but reported at the end
+	out.Reset()
+	code, err = gronInputs(namedInputs([]string{"missing.json", "b.json"}), out, optMonochrome, "json", open, cfg.gronWithPrefix, cfg.gronStreamWithPrefix)
+	if code != exitPartial {
+		t.Errorf("want exitPartial; have %d", code)
+	}
+	if errs, ok := err.(inputErrors); !ok || len(errs) != 1 {
+		t.Errorf("want one input error; have %#v", err)
+	}
+	if !strings.Contains(out.String(), `json["b.json"][0] = 1;`) {
+		t.Errorf("want good input to be gronned after a bad one; have %s", out.String())
+	}
+
+	// If nothing could be gronned the exit code is for the first error
+	out.Reset()
+	code, _ = gronInputs(namedInputs([]string{"missing.json"}), out, optMonochrome, "json", open, cfg.gronWithPrefix, cfg.gronStreamWithPrefix)
+	if code != exitOpenFile {
+		t.Errorf("want exitOpenFile; have %d", code)
+	}
+}
+
+func TestGronInputsStream(t *testing.T) {
+	open := stringOpener(map[string]string{
+		"a.ndjson": "{\"n\": 1}\n{\"n\": 2}\n",
+	})
+
+	out := &bytes.Buffer{}
+	cfg := defaultActionConfig()
+	inputs := []input{{name: "a.ndjson", path: "a.ndjson", stream: true}}
+	code, err := gronInputs(inputs, out, optMonochrome, "json", open, cfg.gronWithPrefix, cfg.gronStreamWithPrefix)
+	if code != exitOK || err != nil {
+		t.Fatalf("want exitOK and nil error; have %d and %v", code, err)
+	}
+
+	want := strings.Join([]string{
+		`json = {};`,
+		`json["a.ndjson"] = [];`,
+		`json["a.ndjson"][0] = {};`,
+		`json["a.ndjson"][0].n = 1;`,
+		`json["a.ndjson"][1] = {};`,
+		`json["a.ndjson"][1].n = 2;`,
+		``,
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("want:\n%s\nhave:\n%s", want, out.String())
+	}
+}
+
+func TestUngronSplit(t *testing.T) {
+	dir := t.TempDir()
+	in := strings.NewReader(strings.Join([]string{
+		`json = {};`,
+		`json["users.json"] = {};`,
+		`json["users.json"].name = "Tom";`,
+		`json["sub/b.json"] = [];`,
+		`json["sub/b.json"][0] = 1;`,
+	}, "\n"))
+
+	out := &bytes.Buffer{}
+	code, err := defaultActionConfig().ungronSplit(in, out, optMonochrome, dir)
+	if code != exitOK {
+		t.Errorf("want exitOK; have %d", code)
+	}
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+
+	files := map[string]string{
+		"users.json": "{\n  \"name\": \"Tom\"\n}\n",
+		"sub/b.json": "[\n  1\n]\n",
+	}
+	for name, want := range files {
+		have, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read output file: %s", err)
+		}
+		if string(have) != want {
+			t.Errorf("want %s to contain %q; have %q", name, want, have)
+		}
+	}
+
+	in = strings.NewReader(`json["../escape.json"] = 1;`)
+	code, err = defaultActionConfig().ungronSplit(in, out, optMonochrome, dir)
+	if code != exitWriteFile || err == nil {
+		t.Errorf("want exitWriteFile and an error for non-local name; have %d, %v", code, err)
+	}
+}
+
+func TestConcatInputsLocate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.gron": "json.a = 1;\njson.b = 2;",
+		"b.gron": "",
+		"c.gron": "json.c = 3;\njson.d = ;\n",
+	}
+	names := make([]string, 0, len(files))
+	for _, name := range []string{"a.gron", "b.gron", "c.gron"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("failed to write test file: %s", err)
+		}
+		names = append(names, path)
+	}
+
+	open := func(name string) (io.Reader, int, error) {
+		f, err := os.Open(name)
+		return f, exitOpenFile, err
+	}
+	r, _, err := concatInputs(names, open)
+	if err != nil {
+		t.Fatalf("want nil error from concatInputs; have %s", err)
+	}
+	defer closeInput(r)
+
+	_, code, err := defaultActionConfig().ungronToInterface(r, optKeepGoing)
+	if code != exitParseStatements {
+		t.Errorf("want exitParseStatements; have %d", code)
+	}
+	want := names[2] + ":2:10: "
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("want error starting with %q; have %v", want, err)
+	}
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
@@ -26,6 +28,9 @@ const (
 	exitFetchURL
 	exitParseStatements
 	exitJSONEncode
+	exitInvalidOption
+	exitWriteFile
+	exitPartial
 )
 
 // Option bitfields
@@ -33,6 +38,18 @@ const (
 	optMonochrome = 1 << iota
 	optNoSort
 	optJSON
+	optKeepGoing
+	optStrict
+	optSparseCompact
+	optSparseObject
+	optConcat
+	optSeq
+	optNDJSON
+	optSorted
+	optSkipInvalid
+	optDuplicates
+	optLint
+	optExpandJSON
 )
 
 // Output colors
@@ -58,7 +75,7 @@ func init() {
 		h := "Transform JSON (from a file, URL, or stdin) into discrete assignments to make it greppable\n\n"
 
 		h += "Usage:\n"
-		h += "  gron [OPTIONS] [FILE|URL|-]\n\n"
+		h += "  gron [OPTIONS] [FILE|URL|-]...\n\n"
 
 		h += "Options:\n"
 		h += "  -u, --ungron     Reverse the operation (turn assignments back into JSON)\n"
@@ -66,11 +83,79 @@ func init() {
 		h += "  -c, --colorize   Colorize output (default on tty)\n"
 		h += "  -m, --monochrome Monochrome (don't colorize output)\n"
 		h += "  -s, --stream     Treat each line of input as a separate JSON object\n"
+		h += "      --concat     Like --stream, but allow any sequence of JSON values; e.g. pretty-printed objects\n"
+		h += "      --seq        Like --stream, but read an RFC 7464 JSON text sequence. When ungronning, write a\n"
+		h += "                   JSON text sequence instead, with a record for each json[n]\n"
+		h += "      --ndjson     When ungronning, write one line of JSON for each json[n]\n"
+		h += "      --sorted     With --ndjson or --seq, write each record as soon as it's complete, so endless\n"
+		h += "                   streams can be ungronned; the statements must be sorted, like those from --stream\n"
+		h += "      --skip-invalid\n"
+		h += "                   With --stream, report lines that aren't valid JSON and carry on instead of stopping\n"
+		h += "      --invalid-log\n"
+		h += "                   Write the lines skipped with --skip-invalid to this file instead of stderr\n"
+		h += "  -f, --follow     Like tail -f; keep gronning records as they're added to a file. Implies --stream\n"
+		h += "      --workers    The number of records to gron at the same time with --stream (default: the number of CPUs)\n"
 		h += "  -k, --insecure   Disable certificate validation\n"
+		h += "      --cacert     Verify servers with the CAs in this PEM file instead of the system's\n"
+		h += "      --cert       Use the client certificate in this PEM file for URLs; the key can be in it too\n"
+		h += "      --key        The PEM file with the key for --cert\n"
+		h += "      --tls-min    The minimum TLS version to use: 1.0, 1.1, 1.2 or 1.3\n"
+		h += "      --sni        The server name to send and verify instead of the URL's host\n"
+		h += "      --resolve    Connect to an address instead of looking up a host, in the form host:port:address (repeatable)\n"
 		h += "  -x, --proxy      Set proxy configuration\n"
+		h += "      --timeout    How long fetching a URL may take, e.g. 30s or 2m (default 20s, 0 for no limit)\n"
+		h += "      --retries    How many times to retry fetching a URL after an error, a 429 or a 5xx response (default 0).\n"
+		h += "                   Only GET, HEAD, PUT, DELETE and OPTIONS requests are retried unless --retry-all is given\n"
+		h += "      --retry-all  Retry requests with any method, like POST, which might repeat what they did\n"
+		h += "      --retry-wait How long to wait before the first retry; it doubles each time unless there's a Retry-After (default 1s)\n"
+		h += "      --ignore-status\n"
+		h += "                   Gron the body of non-2xx responses instead of failing\n"
+		h += "      --cache      Cache responses for URLs in the user cache dir (e.g. ~/.cache/gron), and use them while they're fresh\n"
+		h += "      --cache-ttl  How long cached responses are fresh for, e.g. 10m, instead of going by their headers. Implies --cache\n"
+		h += "      --no-cache   Fetch URLs again even if they're cached, but still cache the responses\n"
+		h += "      --response   Gron the status, headers and timing of URLs as well, with the body under json.body (implies --ignore-status)\n"
+		h += "  -X, --request    The HTTP method to use for URLs (default GET, or POST with --data)\n"
+		h += "  -H, --header     Add a header to requests for URLs, e.g. -H 'Authorization: Bearer $TOKEN' (repeatable)\n"
+		h += "      --data       Send a request body with URLs; @file reads it from a file, @- from stdin\n"
+		h += "      --data-file  Send the contents of a file (or - for stdin) as the request body\n"
+		h += "      --user       Use basic auth for URLs, in the form user:password\n"
+		h += "      --token-env  Send a bearer token for URLs from this environment variable\n"
+		h += "      --token-file Send a bearer token for URLs from this file\n"
+		h += "  -n, --netrc      Look up credentials for URLs by host in ~/.netrc\n"
+		h += "      --netrc-file Like --netrc, but use this file\n"
+		h += "      --paginate   Follow the rel=\"next\" Link headers of a URL and gron every page as json[n]\n"
+		h += "      --next       With --paginate, the path to the next page's URL or cursor instead; e.g. json.meta.next\n"
+		h += "      --cursor-param\n"
+		h += "                   With --next, the query parameter to put the cursor in on the first page's URL\n"
+		h += "      --flatten    With --paginate, gron the items on every page as one array instead of each page\n"
+		h += "      --items      With --flatten, the path to the array of items on each page; e.g. json.data\n"
+		h += "      --max-pages  The most pages to fetch with --paginate (default 100, 0 for no limit)\n"
 		h += "      --noproxy    Comma-separated list of hosts for which not to use a proxy, if one is specified.\n"
 		h += "  -j, --json       Represent gron data as JSON stream\n"
+		h += "      --har        Read HAR files from browser devtools, with the entries as json.entries[n] and JSON bodies decoded\n"
+		h += "      --with-filename\n"
+		h += "                   Put each input under its own key, e.g. json[\"users.json\"] (default with more than one input)\n"
+		h += "      --root       The name of the root of the statements (default json)\n"
+		h += "      --compress   Compress the output with gzip, zstd or xz; e.g. gron -u --compress=gzip > out.json.gz\n"
+		h += "      --split      When ungronning, write the JSON for each input to a separate file in this directory\n"
+		h += "  -r, --recursive  Gron every JSON-like file in the named directories (default .)\n"
+		h += "      --include    Only gron files matching this glob when using --recursive (repeatable)\n"
+		h += "      --exclude    Skip files and directories matching this glob when using --recursive (repeatable)\n"
+		h += "      --ignore-file\n"
+		h += "                   A .gitignore-style file of paths to skip when using --recursive (" + defaultIgnoreFile + " is used automatically)\n"
 		h += "      --no-sort    Don't sort output (faster)\n"
+		h += "      --expand-json\n"
+		h += "                   Expand strings that hold JSON objects or arrays into statements, marked so ungron turns them back into strings\n"
+		h += "      --decode     Decode strings at paths matching a pattern: jwt, base64 or query, e.g. --decode 'jwt=json.**.token' (repeatable)\n"
+		h += "      --duplicates Output every occurrence of duplicate object keys, flagged with a comment\n"
+		h += "      --lint       Warn about duplicate object keys, with their byte offsets\n"
+		h += "      --keep-going When ungronning, report every invalid statement instead of stopping at the first\n"
+		h += "      --strict     When ungronning, reject any statement that doesn't exactly match the grammar\n"
+		h += "      --sparse     When ungronning, how to handle arrays with missing indexes: null (default), compact or object\n"
+		h += "      --max-array-gap\n"
+		h += "                   When ungronning with --sparse=null, the most missing indexes an array may have (default 1048576, 0 for no limit)\n"
+		h += "      --max-line-size\n"
+		h += "                   The longest line or record in bytes when reading a line at a time, e.g. with --stream or --ungron (default 0, no limit)\n"
 		h += "      --version    Print version information\n\n"
 
 		h += "Exit Codes:\n"
@@ -81,6 +166,9 @@ func init() {
 		h += fmt.Sprintf("  %d\t%s\n", exitFetchURL, "Failed to fetch URL")
 		h += fmt.Sprintf("  %d\t%s\n", exitParseStatements, "Failed to parse statements")
 		h += fmt.Sprintf("  %d\t%s\n", exitJSONEncode, "Failed to encode JSON")
+		h += fmt.Sprintf("  %d\t%s\n", exitInvalidOption, "Invalid option value")
+		h += fmt.Sprintf("  %d\t%s\n", exitWriteFile, "Failed to write file")
+		h += fmt.Sprintf("  %d\t%s\n", exitPartial, "Some invalid input was skipped")
 		h += "\n"
 
 		h += "Examples:\n"
@@ -88,6 +176,7 @@ func init() {
 		h += "  gron http://jsonplaceholder.typicode.com/users/1 \n"
 		h += "  curl -s http://jsonplaceholder.typicode.com/users/1 | gron\n"
 		h += "  gron http://jsonplaceholder.typicode.com/users/1 | grep company | gron --ungron\n"
+		h += "  gron *.json | grep email | gron --ungron --split out/\n"
 
 		fmt.Fprint(os.Stderr, h)
 	}
@@ -95,19 +184,75 @@ func init() {
 
 func main() {
 	var (
-		ungronFlag     bool
-		colorizeFlag   bool
-		monochromeFlag bool
-		streamFlag     bool
-		noSortFlag     bool
-		versionFlag    bool
-		insecureFlag   bool
-		jsonFlag       bool
-		valuesFlag     bool
-		proxyURL       string
-		noProxy        string
+		ungronFlag       bool
+		colorizeFlag     bool
+		monochromeFlag   bool
+		streamFlag       bool
+		noSortFlag       bool
+		versionFlag      bool
+		insecureFlag     bool
+		jsonFlag         bool
+		valuesFlag       bool
+		keepGoingFlag    bool
+		duplicatesFlag   bool
+		lintFlag         bool
+		skipInvalidFlag  bool
+		invalidLogFile   string
+		strictFlag       bool
+		sparseMode       string
+		withFilenameFlag bool
+		rootName         string
+		splitDir         string
+		compressFormat   string
+		recursiveFlag    bool
+		includePatterns  stringList
+		excludePatterns  stringList
+		ignoreFile       string
+		concatFlag       bool
+		followFlag       bool
+		seqFlag          bool
+		ndjsonFlag       bool
+		sortedFlag       bool
+		proxyURL         string
+		noProxy          string
+		method           string
+		headers          stringList
+		data             string
+		dataFile         string
+		user             string
+		tokenEnv         string
+		tokenFile        string
+		netrcFlag        bool
+		netrcFile        string
+		paginateFlag     bool
+		nextPath         string
+		cursorParam      string
+		flattenFlag      bool
+		itemsPath        string
+		maxPages         int
+		timeout          time.Duration
+		retries          int
+		retryWait        time.Duration
+		retryAllFlag     bool
+		ignoreStatusFlag bool
+		responseFlag     bool
+		cacheFlag        bool
+		cacheTTL         time.Duration
+		noCacheFlag      bool
+		caCert           string
+		certFile         string
+		keyFile          string
+		tlsMin           string
+		serverName       string
+		resolve          stringList
+		harFlag          bool
+		expandJSONFlag   bool
+		decodeArgs       stringList
 	)
 
+	// Settings for the actions that aren't just on or off
+	cfg := defaultActionConfig()
+
 	flag.BoolVar(&ungronFlag, "ungron", false, "")
 	flag.BoolVar(&ungronFlag, "u", false, "")
 	flag.BoolVar(&colorizeFlag, "colorize", false, "")
@@ -116,18 +261,79 @@ func main() {
 	flag.BoolVar(&monochromeFlag, "m", false, "")
 	flag.BoolVar(&streamFlag, "s", false, "")
 	flag.BoolVar(&streamFlag, "stream", false, "")
+	flag.BoolVar(&concatFlag, "concat", false, "")
+	flag.BoolVar(&followFlag, "f", false, "")
+	flag.BoolVar(&followFlag, "follow", false, "")
+	flag.BoolVar(&seqFlag, "seq", false, "")
+	flag.BoolVar(&ndjsonFlag, "ndjson", false, "")
+	flag.BoolVar(&sortedFlag, "sorted", false, "")
 	flag.BoolVar(&noSortFlag, "no-sort", false, "")
 	flag.BoolVar(&versionFlag, "version", false, "")
 	flag.BoolVar(&insecureFlag, "k", false, "")
 	flag.BoolVar(&insecureFlag, "insecure", false, "")
+	flag.StringVar(&caCert, "cacert", "", "")
+	flag.StringVar(&certFile, "cert", "", "")
+	flag.StringVar(&keyFile, "key", "", "")
+	flag.StringVar(&tlsMin, "tls-min", "", "")
+	flag.StringVar(&serverName, "sni", "", "")
+	flag.Var(&resolve, "resolve", "")
 	flag.BoolVar(&jsonFlag, "j", false, "")
 	flag.BoolVar(&jsonFlag, "json", false, "")
 	flag.BoolVar(&valuesFlag, "values", false, "")
 	flag.BoolVar(&valuesFlag, "value", false, "")
 	flag.BoolVar(&valuesFlag, "v", false, "")
+	flag.BoolVar(&keepGoingFlag, "keep-going", false, "")
+	flag.BoolVar(&duplicatesFlag, "duplicates", false, "")
+	flag.BoolVar(&lintFlag, "lint", false, "")
+	flag.BoolVar(&expandJSONFlag, "expand-json", false, "")
+	flag.Var(&decodeArgs, "decode", "")
+	flag.BoolVar(&skipInvalidFlag, "skip-invalid", false, "")
+	flag.StringVar(&invalidLogFile, "invalid-log", "", "")
+	flag.BoolVar(&strictFlag, "strict", false, "")
+	flag.StringVar(&sparseMode, "sparse", "null", "")
+	flag.IntVar(&cfg.maxArrayGap, "max-array-gap", cfg.maxArrayGap, "")
+	flag.IntVar(&cfg.maxLineSize, "max-line-size", cfg.maxLineSize, "")
+	flag.IntVar(&cfg.workers, "workers", cfg.workers, "")
+	flag.BoolVar(&harFlag, "har", false, "")
+	flag.BoolVar(&withFilenameFlag, "with-filename", false, "")
+	flag.StringVar(&rootName, "root", "json", "")
+	flag.StringVar(&splitDir, "split", "", "")
+	flag.StringVar(&compressFormat, "compress", "", "")
+	flag.BoolVar(&recursiveFlag, "r", false, "")
+	flag.BoolVar(&recursiveFlag, "recursive", false, "")
+	flag.Var(&includePatterns, "include", "")
+	flag.Var(&excludePatterns, "exclude", "")
+	flag.StringVar(&ignoreFile, "ignore-file", "", "")
 	flag.StringVar(&proxyURL, "x", undefinedProxy, "")
 	flag.StringVar(&proxyURL, "proxy", undefinedProxy, "")
 	flag.StringVar(&noProxy, "noproxy", undefinedProxy, "")
+	flag.DurationVar(&timeout, "timeout", 20*time.Second, "")
+	flag.IntVar(&retries, "retries", 0, "")
+	flag.DurationVar(&retryWait, "retry-wait", time.Second, "")
+	flag.BoolVar(&retryAllFlag, "retry-all", false, "")
+	flag.BoolVar(&ignoreStatusFlag, "ignore-status", false, "")
+	flag.BoolVar(&responseFlag, "response", false, "")
+	flag.BoolVar(&cacheFlag, "cache", false, "")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "")
+	flag.BoolVar(&noCacheFlag, "no-cache", false, "")
+	flag.StringVar(&method, "X", "", "")
+	flag.StringVar(&method, "request", "", "")
+	flag.Var(&headers, "H", "")
+	flag.Var(&headers, "header", "")
+	flag.StringVar(&data, "data", "", "")
+	flag.StringVar(&dataFile, "data-file", "", "")
+	flag.StringVar(&user, "user", "", "")
+	flag.StringVar(&tokenEnv, "token-env", "", "")
+	flag.StringVar(&tokenFile, "token-file", "", "")
+	flag.BoolVar(&netrcFlag, "n", false, "")
+	flag.BoolVar(&netrcFlag, "netrc", false, "")
+	flag.StringVar(&netrcFile, "netrc-file", "", "")
+	flag.BoolVar(&paginateFlag, "paginate", false, "")
+	flag.StringVar(&nextPath, "next", "", "")
+	flag.StringVar(&cursorParam, "cursor-param", "", "")
+	flag.BoolVar(&flattenFlag, "flatten", false, "")
+	flag.StringVar(&itemsPath, "items", "", "")
+	flag.IntVar(&maxPages, "max-pages", 100, "")
 
 	flag.Parse()
 
@@ -142,24 +348,224 @@ func main() {
 		ungronFlag = true
 	}
 
-	// Determine what the program's input should be:
-	// file, HTTP URL or stdin
-	var rawInput io.Reader
-	filename := flag.Arg(0)
-	if filename == "" || filename == "-" {
-		rawInput = os.Stdin
-	} else if validURL(filename) {
-		r, err := getURL(filename, insecureFlag, proxyURL, noProxy)
+	// Determine what the program's inputs should be:
+	// files, HTTP URLs or stdin
+	names, err := expandInputs(flag.Args())
+	if err != nil {
+		fatal(exitOpenFile, err)
+	}
+	if len(names) == 0 {
+		names = []string{"-"}
+		if recursiveFlag {
+			names = []string{"."}
+		}
+	}
+
+	// URLs are fetched with the method, headers and body given
+	reqConfig := requestConfig{
+		insecure: insecureFlag,
+		proxyURL: proxyURL,
+		noProxy:  noProxy,
+		method:   method,
+		user:     user,
+
+		caCert:     caCert,
+		cert:       certFile,
+		key:        keyFile,
+		serverName: serverName,
+
+		timeout:      timeout,
+		retries:      retries,
+		retryWait:    retryWait,
+		retryAll:     retryAllFlag,
+		ignoreStatus: ignoreStatusFlag,
+	}
+	if keyFile != "" && certFile == "" {
+		fatal(exitInvalidOption, fmt.Errorf("--key needs --cert"))
+	}
+	if tlsMin != "" {
+		reqConfig.tlsMin, err = parseTLSVersion(tlsMin)
 		if err != nil {
-			fatal(exitFetchURL, err)
+			fatal(exitInvalidOption, err)
 		}
-		rawInput = r
-	} else {
-		r, err := os.Open(filename)
+	}
+	reqConfig.resolve, err = parseResolve(resolve)
+	if err != nil {
+		fatal(exitInvalidOption, err)
+	}
+	// The certificates are loaded now so mistakes are found before anything's fetched
+	if _, err := reqConfig.tlsConfig(); err != nil {
+		fatal(exitInvalidOption, err)
+	}
+
+	if timeout < 0 {
+		fatal(exitInvalidOption, fmt.Errorf("invalid --timeout %s; must be 0 or more", timeout))
+	}
+	if retries < 0 {
+		fatal(exitInvalidOption, fmt.Errorf("invalid --retries %d; must be 0 or more", retries))
+	}
+	if retryWait < 0 {
+		fatal(exitInvalidOption, fmt.Errorf("invalid --retry-wait %s; must be 0 or more", retryWait))
+	}
+
+	if cacheFlag || cacheTTL != 0 {
+		if cacheTTL < 0 {
+			fatal(exitInvalidOption, fmt.Errorf("invalid --cache-ttl %s; must be 0 or more", cacheTTL))
+		}
+		dir, err := defaultCacheDir()
+		if err != nil {
+			fatal(exitInvalidOption, fmt.Errorf("failed to find the cache directory: %s", err))
+		}
+		reqConfig.cache = &httpCache{dir: dir, ttl: cacheTTL, reload: noCacheFlag}
+	}
+	reqConfig.header, err = parseHeaders(headers)
+	if err != nil {
+		fatal(exitInvalidOption, err)
+	}
+
+	if tokenEnv != "" || tokenFile != "" {
+		if user != "" || (tokenEnv != "" && tokenFile != "") {
+			fatal(exitInvalidOption, fmt.Errorf("only one of --user, --token-env and --token-file can be used"))
+		}
+		reqConfig.token, err = readToken(tokenEnv, tokenFile)
+		if err != nil {
+			fatal(exitInvalidOption, fmt.Errorf("failed to read bearer token: %s", err))
+		}
+	}
+
+	if netrcFlag || netrcFile != "" {
+		if netrcFile == "" {
+			netrcFile = defaultNetrcFile()
+		}
+		reqConfig.netrc, err = readNetrc(netrcFile)
+		if err != nil {
+			fatal(exitOpenFile, fmt.Errorf("failed to read .netrc: %s", err))
+		}
+	}
+	if data != "" && dataFile != "" {
+		fatal(exitInvalidOption, fmt.Errorf("only one of --data and --data-file can be used"))
+	}
+	if data == "@-" || dataFile == "-" {
+		for _, name := range names {
+			if name == "-" {
+				fatal(exitInvalidOption, fmt.Errorf("stdin can't be used for both the request body and an input"))
+			}
+		}
+	}
+	switch {
+	case data != "":
+		reqConfig.body, err = readData(data)
+	case dataFile != "":
+		reqConfig.body, err = readDataFile(dataFile)
+	}
+	if err != nil {
+		fatal(exitOpenFile, fmt.Errorf("failed to read request body: %s", err))
+	}
+
+	// Paginated APIs are read as a stream of pages, or of the
+	// items on them, so only one URL can be paginated at a time
+	var pageCfg pageConfig
+	if nextPath != "" || itemsPath != "" || flattenFlag {
+		paginateFlag = true
+	}
+	if paginateFlag {
+		if ungronFlag || valuesFlag || followFlag || len(names) != 1 || !validURL(names[0]) {
+			fatal(exitInvalidOption, fmt.Errorf("--paginate needs exactly one URL to gron"))
+		}
+		if responseFlag {
+			fatal(exitInvalidOption, fmt.Errorf("--response can't be used with --paginate"))
+		}
+		if cursorParam != "" && nextPath == "" {
+			fatal(exitInvalidOption, fmt.Errorf("--cursor-param needs --next to say where the cursor is"))
+		}
+		if maxPages < 0 {
+			fatal(exitInvalidOption, fmt.Errorf("invalid --max-pages %d; must be 0 or more", maxPages))
+		}
+		pageCfg = pageConfig{
+			cursorParam: cursorParam,
+			flatten:     flattenFlag || itemsPath != "",
+			maxPages:    maxPages,
+		}
+		if nextPath != "" {
+			pageCfg.next, err = parsePath(nextPath)
+			if err != nil {
+				fatal(exitInvalidOption, fmt.Errorf("invalid --next: %s", err))
+			}
+		}
+		if itemsPath != "" {
+			pageCfg.items, err = parsePath(itemsPath)
+			if err != nil {
+				fatal(exitInvalidOption, fmt.Errorf("invalid --items: %s", err))
+			}
+		}
+		concatFlag = true
+	}
+
+	openRaw := func(name string) (io.Reader, int, error) {
+		if name == "" || name == "-" {
+			return os.Stdin, exitOK, nil
+		}
+		if paginateFlag {
+			return paginate(name, reqConfig, pageCfg), exitOK, nil
+		}
+		if validURL(name) && responseFlag {
+			return fetchResponse(name, reqConfig)
+		}
+		if validURL(name) {
+			r, err := getURL(name, reqConfig)
+			if err != nil {
+				return nil, exitFetchURL, err
+			}
+			return r, exitOK, nil
+		}
+		if followFlag {
+			r, err := newFollowReader(name)
+			if err != nil {
+				return nil, exitOpenFile, err
+			}
+			return r, exitOK, nil
+		}
+		r, err := os.Open(name)
 		if err != nil {
-			fatal(exitOpenFile, err)
+			return nil, exitOpenFile, err
 		}
-		rawInput = r
+		return r, exitOK, nil
+	}
+
+	// Compressed inputs are decompressed transparently
+	open := func(name string) (io.Reader, int, error) {
+		r, code, err := openRaw(name)
+		if err != nil {
+			return nil, code, err
+		}
+		d, err := decompress(r)
+		if err != nil {
+			closeInput(r)
+			return nil, exitReadInput, err
+		}
+		if harFlag {
+			h, err := readHAR(d)
+			if err != nil {
+				closeInput(d)
+				return nil, exitReadInput, fmt.Errorf("%s: %s", inputName(d), err)
+			}
+			return h, exitOK, nil
+		}
+		return d, exitOK, nil
+	}
+
+	// HAR files are one JSON document, with the bodies decoded
+	if harFlag && (ungronFlag || valuesFlag || streamFlag || concatFlag || seqFlag || ndjsonFlag || followFlag || paginateFlag) {
+		fatal(exitInvalidOption, fmt.Errorf("--har can only be used for gronning whole files"))
+	}
+
+	// Following a file only makes sense for a stream of records,
+	// and only one file can be followed because it never ends
+	if followFlag {
+		if ungronFlag || valuesFlag || len(names) != 1 || names[0] == "-" || validURL(names[0]) {
+			fatal(exitInvalidOption, fmt.Errorf("--follow needs exactly one file to gron"))
+		}
+		streamFlag = true
 	}
 
 	var opts int
@@ -174,20 +580,153 @@ func main() {
 	if noSortFlag {
 		opts = opts | optNoSort
 	}
+	if sortedFlag {
+		if !ungronFlag || !(seqFlag || ndjsonFlag) {
+			fatal(exitInvalidOption, fmt.Errorf("--sorted can only be used when ungronning with --ndjson or --seq"))
+		}
+		opts = opts | optSorted
+	}
+	if concatFlag {
+		opts = opts | optConcat
+		streamFlag = true
+	}
+	if seqFlag {
+		opts = opts | optSeq
+		streamFlag = true
+	}
+	if ndjsonFlag {
+		opts = opts | optNDJSON
+		streamFlag = true
+	}
 	if jsonFlag {
 		opts = opts | optJSON
 	}
+	if keepGoingFlag {
+		opts = opts | optKeepGoing
+	}
+	if strictFlag {
+		opts = opts | optStrict
+	}
+	if duplicatesFlag {
+		opts = opts | optDuplicates
+	}
+	if lintFlag {
+		opts = opts | optLint
+	}
+	if expandJSONFlag {
+		opts = opts | optExpandJSON
+	}
+	for _, arg := range decodeArgs {
+		d, err := parseDecoder(arg)
+		if err != nil {
+			fatal(exitInvalidOption, err)
+		}
+		cfg.decoders = append(cfg.decoders, d)
+	}
+	if skipInvalidFlag {
+		opts = opts | optSkipInvalid
+	}
+	if invalidLogFile != "" {
+		f, err := os.Create(invalidLogFile)
+		if err != nil {
+			fatal(exitWriteFile, err)
+		}
+		defer f.Close()
+		cfg.invalidLog = f
+	}
+	switch sparseMode {
+	case "null":
+		// Nothing to do; it's the default
+	case "compact":
+		opts = opts | optSparseCompact
+	case "object":
+		opts = opts | optSparseObject
+	default:
+		fatal(exitInvalidOption, fmt.Errorf("invalid --sparse mode %q; must be null, compact or object", sparseMode))
+	}
+	if cfg.workers < 1 {
+		fatal(exitInvalidOption, fmt.Errorf("invalid --workers %d; must be 1 or more", cfg.workers))
+	}
+	if cfg.maxLineSize < 0 {
+		fatal(exitInvalidOption, fmt.Errorf("invalid --max-line-size %d; must be 0 or more", cfg.maxLineSize))
+	}
 
-	// Pick the appropriate action: gron, ungron, gronValues, or gronStream
-	var a actionFn = gron
-	if ungronFlag {
-		a = ungron
-	} else if valuesFlag {
-		a = gronValues
-	} else if streamFlag {
-		a = gronStream
+	// gron and gronStream are used with a prefix so that
+	// the name of the root can be changed
+	var g prefixedActionFn = cfg.gronWithPrefix
+	if streamFlag {
+		g = cfg.gronStreamWithPrefix
+	}
+
+	var exitCode int
+	out := colorable.NewColorableStdout()
+
+	// Compressed output is never colorized
+	var cw io.WriteCloser
+	if compressFormat != "" {
+		if splitDir != "" {
+			fatal(exitInvalidOption, fmt.Errorf("--compress can't be used with --split"))
+		}
+		cw, err = compressWriter(os.Stdout, compressFormat)
+		if err != nil {
+			fatal(exitInvalidOption, err)
+		}
+		out = cw
+		opts = opts | optMonochrome
+	}
+
+	if !ungronFlag && !valuesFlag && (len(names) > 1 || withFilenameFlag || recursiveFlag) {
+		inputs := namedInputs(names)
+		if recursiveFlag {
+			inputs, err = walkInputs(names, walkConfig{
+				include:    includePatterns,
+				exclude:    excludePatterns,
+				ignoreFile: ignoreFile,
+			})
+			if err != nil {
+				fatal(exitOpenFile, err)
+			}
+		}
+
+		// When gronning more than one input, each one gets its own
+		// key under the root so the statements can be told apart
+		exitCode, err = gronInputs(inputs, out, opts, rootName, open, g, cfg.gronStreamWithPrefix)
+
+	} else {
+		// Otherwise the single JSON input, or any statements, are read in one go
+		var rawInput io.Reader
+		rawInput, exitCode, err = concatInputs(names, open)
+		if err != nil {
+			fatal(exitCode, err)
+		}
+
+		// Pick the appropriate action: gron, ungron, gronValues, or gronStream
+		var a actionFn = func(r io.Reader, w io.Writer, opts int) (int, error) {
+			return g(r, w, opts, statement{{rootName, typBare}})
+		}
+		if ungronFlag && splitDir != "" {
+			a = func(r io.Reader, w io.Writer, opts int) (int, error) {
+				return cfg.ungronSplit(r, w, opts, splitDir)
+			}
+		} else if ungronFlag && opts&optSorted > 0 {
+			a = cfg.ungronStream
+		} else if ungronFlag && (seqFlag || ndjsonFlag) {
+			a = cfg.ungronRecords
+		} else if ungronFlag {
+			a = cfg.ungron
+		} else if valuesFlag {
+			a = cfg.gronValues
+		}
+		exitCode, err = a(rawInput, out, opts)
+	}
+
+	// Anything still buffered by the compressor has to be
+	// written before exiting, even if there was an error
+	if cw != nil {
+		if cerr := cw.Close(); cerr != nil && exitCode == exitOK {
+			exitCode, err = exitWriteFile, fmt.Errorf("failed to write compressed output: %s", cerr)
+		}
 	}
-	exitCode, err := a(rawInput, colorable.NewColorableStdout(), opts)
 
 	if exitCode != exitOK {
 		fatal(exitCode, err)
@@ -196,16 +735,59 @@ func main() {
 	os.Exit(exitOK)
 }
 
+// stringList is a flag.Value for options that can be given
+// more than once; e.g. --include '*.json' --include '*.har'
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
 // an actionFn represents a main action of the program, it accepts
 // an input, output and a bitfield of options; returning an exit
 // code and any error that occurred
 type actionFn func(io.Reader, io.Writer, int) (int, error)
 
+// An actionConfig holds the settings for the actions that can't go in the
+// bitfield of options because they aren't just on or off. The actions
+// are its methods, so they can be used as actionFns once it's set up
+type actionConfig struct {
+	maxArrayGap int           // The most missing indexes an array may have when ungronning; 0 for no limit
+	maxLineSize int           // The longest line or record when reading a line at a time; 0 for no limit
+	workers     int           // The number of records gronStream turns into statements at once
+	invalidLog  io.Writer     // Where records skipped with optSkipInvalid are reported
+	decoders    valueDecoders // The decoders given with --decode
+}
+
+// defaultActionConfig returns the settings that are used unless
+// they're changed with options
+func defaultActionConfig() actionConfig {
+	return actionConfig{
+		maxArrayGap: 1 << 20,
+		workers:     runtime.NumCPU(),
+		invalidLog:  os.Stderr,
+	}
+}
+
 // gron is the default action. Given JSON as the input it returns a list
 // of assignment statements. Possible options are optNoSort and optMonochrome
-func gron(r io.Reader, w io.Writer, opts int) (int, error) {
+func (c actionConfig) gron(r io.Reader, w io.Writer, opts int) (int, error) {
+	return c.gronWithPrefix(r, w, opts, statement{{"json", typBare}})
+}
+
+// gronWithPrefix is like gron, but every statement starts
+// with the provided prefix instead of just 'json'
+func (c actionConfig) gronWithPrefix(r io.Reader, w io.Writer, opts int, prefix statement) (int, error) {
 	var err error
 
+	var ss statements
+	var dups []duplicateKey
+
 	var conv statementconv
 	if opts&optMonochrome > 0 {
 		conv = statementToString
@@ -213,15 +795,35 @@ func gron(r io.Reader, w io.Writer, opts int) (int, error) {
 		conv = statementToColorString
 	}
 
-	ss, err := statementsFromJSON(r, statement{{"json", typBare}})
+	// Duplicate keys can only be found by walking the raw tokens
+	if opts&(optDuplicates|optLint) > 0 {
+		var data []byte
+		data, err = io.ReadAll(r)
+		if err != nil {
+			goto out
+		}
+		ss, dups, err = statementsFromJSONTokens(data, prefix, opts&optDuplicates > 0)
+	} else {
+		ss, err = statementsFromJSON(r, prefix)
+	}
 	if err != nil {
 		goto out
 	}
 
+	if opts&optLint > 0 {
+		for _, d := range dups {
+			warn("%s: %s", inputName(r), d)
+		}
+	}
+
+	if e := expanders(opts, c.decoders); len(e) > 0 {
+		ss = ss.expandStrings(e...)
+	}
+
 	// Go's maps do not have well-defined ordering, but we want a consistent
 	// output for a given input, so we must sort the statements
 	if opts&optNoSort == 0 {
-		sort.Sort(ss)
+		ss.sort(opts)
 	}
 
 	for _, s := range ss {
@@ -242,15 +844,18 @@ out:
 }
 
 // gronStream is like the gron action, but it treats the input as one
-// JSON object per line. There's a bit of code duplication from the
+// JSON object per line, or with optConcat as any sequence of concatenated
+// JSON values. There's a bit of code duplication from the
 // gron action, but it'd be fairly messy to combine the two actions
-func gronStream(r io.Reader, w io.Writer, opts int) (int, error) {
-	var err error
-	errstr := "failed to form statements"
-	var i int
-	var sc *bufio.Scanner
-	var buf []byte
+func (c actionConfig) gronStream(r io.Reader, w io.Writer, opts int) (int, error) {
+	return c.gronStreamWithPrefix(r, w, opts, statement{{"json", typBare}})
+}
 
+// gronStreamWithPrefix is like gronStream, but every statement
+// starts with the provided prefix instead of just 'json'. Records are
+// gronned by c.workers goroutines at once, but are always written
+// in the same order they were read
+func (c actionConfig) gronStreamWithPrefix(r io.Reader, w io.Writer, opts int, prefix statement) (int, error) {
 	var conv func(s statement) string
 	if opts&optMonochrome > 0 {
 		conv = statementToString
@@ -258,111 +863,74 @@ func gronStream(r io.Reader, w io.Writer, opts int) (int, error) {
 		conv = statementToColorString
 	}
 
-	// Helper function to make the prefix statements for each line
-	makePrefix := func(index int) statement {
-		return statement{
-			{"json", typBare},
-			{"[", typLBrace},
-			{fmt.Sprintf("%d", index), typNumericKey},
-			{"]", typRBrace},
-		}
-	}
-
 	// The first line of output needs to establish that the top-level
 	// thing is actually an array...
-	top := statement{
-		{"json", typBare},
-		{"=", typEquals},
-		{"[]", typEmptyArray},
-		{";", typSemi},
-	}
+	var top statements
+	top.addWithValue(prefix, token{"[]", typEmptyArray})
 
 	if opts&optJSON > 0 {
-		top, err = top.jsonify()
+		j, err := top[0].jsonify()
 		if err != nil {
-			goto out
+			return exitFormStatements, fmt.Errorf("failed to form statements: %s", err)
 		}
+		top[0] = j
 	}
 
-	fmt.Fprintln(w, conv(top))
+	fmt.Fprintln(w, conv(top[0]))
 
-	// Read the input line by line
-	sc = bufio.NewScanner(r)
-	buf = make([]byte, 0, 64*1024)
-	sc.Buffer(buf, 1024*1024)
-	i = 0
-	for sc.Scan() {
+	// Read the input line by line, or value by value
+	sc := newRecordScanner(r, opts, c.maxLineSize)
+	stop := make(chan struct{})
+	defer close(stop)
 
-		line := bytes.NewBuffer(sc.Bytes())
+	format := func(rec record) formattedRecord {
+		return formatRecord(rec, prefix, opts, c.decoders, conv)
+	}
 
-		var ss statements
-		ss, err = statementsFromJSON(line, makePrefix(i))
-		i++
-		if err != nil {
-			goto out
-		}
+	skipped := 0
+	for f := range formatRecords(sc, c.workers, stop, format) {
+		if f.err != nil {
+			if opts&optSkipInvalid == 0 {
+				return exitFormStatements, fmt.Errorf("failed to form statements: %s", f.err)
+			}
 
-		// Go's maps do not have well-defined ordering, but we want a consistent
-		// output for a given input, so we must sort the statements
-		if opts&optNoSort == 0 {
-			sort.Sort(ss)
+			// The index is still used up so that json[n]
+			// stays in step with the lines of the input
+			fmt.Fprintln(c.invalidLog, invalidRecord(inputName(r), f.rec, f.err))
+			skipped++
+			continue
 		}
-
-		for _, s := range ss {
-			if opts&optJSON > 0 {
-				s, err = s.jsonify()
-				if err != nil {
-					goto out
-				}
-
+		if opts&optLint > 0 {
+			for _, d := range f.dups {
+				warn("%s: %s", recordName(inputName(r), f.rec), d)
 			}
-			fmt.Fprintln(w, conv(s))
 		}
-	}
-	if err = sc.Err(); err != nil {
-		errstr = "error reading multiline input: %s"
+		w.Write(f.out)
 	}
 
-out:
-	if err != nil {
-		return exitFormStatements, fmt.Errorf(errstr+": %s", err)
+	if err := sc.Err(); err != nil {
+		return exitFormStatements, fmt.Errorf("error reading multiline input: %s", err)
 	}
-	return exitOK, nil
-
-}
-
-// ungron is the reverse of gron. Given assignment statements as input,
-// it returns JSON. The only option is optMonochrome
-func ungron(r io.Reader, w io.Writer, opts int) (int, error) {
-	scanner := bufio.NewScanner(r)
-	var maker statementmaker
-
-	// Allow larger internal buffer of the scanner (min: 64KiB ~ max: 1MiB)
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
-
-	if opts&optJSON > 0 {
-		maker = statementFromJSONSpec
-	} else {
-		maker = statementFromStringMaker
+	if s, ok := sc.(*seqScanner); ok && s.Dropped() > 0 {
+		warn("dropped %d truncated or invalid records", s.Dropped())
 	}
 
-	// Make a list of statements from the input
-	var ss statements
-	for scanner.Scan() {
-		s, err := maker(scanner.Text())
-		if err != nil {
-			return exitParseStatements, err
-		}
-		ss.add(s)
+	if skipped == 1 {
+		return exitPartial, fmt.Errorf("skipped 1 invalid record")
 	}
-	if err := scanner.Err(); err != nil {
-		return exitReadInput, fmt.Errorf("failed to read input statements")
+	if skipped > 0 {
+		return exitPartial, fmt.Errorf("skipped %d invalid records", skipped)
 	}
+	return exitOK, nil
+}
 
-	// turn the statements into a single merged interface{} type
-	merged, err := ss.toInterface()
+// ungron is the reverse of gron. Given assignment statements as input,
+// it returns JSON. Possible options are optMonochrome, optJSON,
+// optKeepGoing, optStrict, optSparseCompact and optSparseObject
+func (c actionConfig) ungron(r io.Reader, w io.Writer, opts int) (int, error) {
+	merged, code, err := c.ungronToInterface(r, opts)
 	if err != nil {
-		return exitParseStatements, err
+		return code, err
 	}
 
 	// If there's only one top level key and it's "json", make that the top level thing
@@ -375,12 +943,100 @@ func ungron(r io.Reader, w io.Writer, opts int) (int, error) {
 		}
 	}
 
+	return writeJSON(w, merged, opts)
+}
+
+// ungronSplit is like ungron, but expects the statements to have come from
+// gronning multiple inputs; e.g. json["users.json"].name = "Tom";
+// The JSON for each input is written to a separate file in the directory
+// dir, named after the input. The filenames are written to w
+func (c actionConfig) ungronSplit(r io.Reader, w io.Writer, opts int, dir string) (int, error) {
+	merged, code, err := c.ungronToInterface(r, opts)
+	if err != nil {
+		return code, err
+	}
+
+	// There should be a single root (normally 'json'), with a
+	// key for each input
+	var files map[string]interface{}
+	if mergedMap, ok := merged.(map[string]interface{}); ok && len(mergedMap) == 1 {
+		for _, v := range mergedMap {
+			files, _ = v.(map[string]interface{})
+		}
+	}
+	if files == nil {
+		return exitParseStatements, fmt.Errorf("cannot split statements; they must look like json[\"filename\"]...")
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !filepath.IsLocal(name) {
+			return exitWriteFile, fmt.Errorf("refusing to write %q; it's outside of the output directory", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return exitWriteFile, err
+		}
+
+		f, err := os.Create(path)
+		if err != nil {
+			return exitWriteFile, err
+		}
+
+		// Files are never colorized
+		code, err := writeJSON(f, files[name], opts|optMonochrome)
+		if cerr := f.Close(); err == nil && cerr != nil {
+			code, err = exitWriteFile, cerr
+		}
+		if err != nil {
+			return code, err
+		}
+		fmt.Fprintln(w, path)
+	}
+
+	return exitOK, nil
+}
+
+// ungronToInterface does the work of turning assignment statements into
+// a single merged datastructure for the ungron actions. It returns an
+// exit code and an error on failure
+func (c actionConfig) ungronToInterface(r io.Reader, opts int) (interface{}, int, error) {
+	u := newUngronReader(r, opts, c)
+
+	// Make a list of statements from the input
+	var b ungronBatch
+	for u.scan() {
+		b.add(u.stmt, u.line, u.text)
+	}
+	if u.err != nil {
+		return nil, u.code, u.err
+	}
+
+	merged, err := u.ungron(&b)
+	if err != nil {
+		return nil, exitParseStatements, err
+	}
+	if code, err := u.finish(); err != nil {
+		return nil, code, err
+	}
+	return merged, exitOK, nil
+}
+
+// writeJSON marshals v into indented JSON and writes it to w,
+// colorizing it unless optMonochrome is set
+func writeJSON(w io.Writer, v interface{}, opts int) (int, error) {
 	// Marshal the output into JSON to display to the user
 	out := &bytes.Buffer{}
 	enc := json.NewEncoder(out)
 	enc.SetIndent("", "  ")
 	enc.SetEscapeHTML(false)
-	err = enc.Encode(merged)
+	err := enc.Encode(v)
 	if err != nil {
 		return exitJSONEncode, errors.Wrap(err, "failed to convert statements to JSON")
 	}
@@ -405,7 +1061,10 @@ func ungron(r io.Reader, w io.Writer, opts int) (int, error) {
 	// Fprintf below
 	j = bytes.TrimSpace(j)
 
-	fmt.Fprintf(w, "%s\n", j)
+	_, err = fmt.Fprintf(w, "%s\n", j)
+	if err != nil {
+		return exitWriteFile, errors.Wrap(err, "failed to write JSON")
+	}
 
 	return exitOK, nil
 }
@@ -413,8 +1072,8 @@ func ungron(r io.Reader, w io.Writer, opts int) (int, error) {
 // gronValues prints just the scalar values from some input gron statements
 // without any quotes or anything of that sort; a bit like jq -r
 // e.g. json[0].user.name = "Sam"; -> Sam
-func gronValues(r io.Reader, w io.Writer, opts int) (int, error) {
-	scanner := bufio.NewScanner(os.Stdin)
+func (c actionConfig) gronValues(r io.Reader, w io.Writer, opts int) (int, error) {
+	scanner := newLineScanner(r, c.maxLineSize)
 
 	for scanner.Scan() {
 		s := statementFromString(scanner.Text())
@@ -442,16 +1101,19 @@ func gronValues(r io.Reader, w io.Writer, opts int) (int, error) {
 					// just swallow errors and try to continue
 					continue
 				}
-				fmt.Println(text)
+				fmt.Fprintln(w, text)
 
 			case typNumber, typTrue, typFalse, typNull:
-				fmt.Println(t.text)
+				fmt.Fprintln(w, t.text)
 
 			default:
 				// Nothing
 			}
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return exitReadInput, fmt.Errorf("failed to read input statements: %s", err)
+	}
 
 	return exitOK, nil
 }
@@ -476,6 +1138,23 @@ func colorizeJSON(src []byte) ([]byte, error) {
 	return out.Bytes(), nil
 }
 
+// inputName returns a name for the provided input that's
+// suitable for use in error messages
+func inputName(r io.Reader) string {
+	if r == os.Stdin {
+		return "<stdin>"
+	}
+	if n, ok := r.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return "<input>"
+}
+
+// warn prints a warning message that doesn't stop the program
+func warn(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
+
 func fatal(code int, err error) {
 	fmt.Fprintf(os.Stderr, "%s\n", err)
 	os.Exit(code)
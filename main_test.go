@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -32,7 +33,7 @@ func TestGron(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := gron(in, out, optMonochrome)
+		code, err := defaultActionConfig().gron(in, out, optMonochrome)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -71,7 +72,7 @@ func TestGronStream(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := gronStream(in, out, optMonochrome)
+		code, err := defaultActionConfig().gronStream(in, out, optMonochrome)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -89,6 +90,81 @@ func TestGronStream(t *testing.T) {
 
 }
 
+func TestGronConcatStream(t *testing.T) {
+	cases := []struct {
+		inFile  string
+		outFile string
+	}{
+		{"testdata/concat-stream.json", "testdata/stream.gron"},
+		{"testdata/stream.json", "testdata/stream.gron"},
+		{"testdata/scalar-stream.json", "testdata/scalar-stream.gron"},
+	}
+
+	for _, c := range cases {
+		in, err := os.Open(c.inFile)
+		if err != nil {
+			t.Fatalf("failed to open input file: %s", err)
+		}
+
+		want, err := ioutil.ReadFile(c.outFile)
+		if err != nil {
+			t.Fatalf("failed to open want file: %s", err)
+		}
+
+		out := &bytes.Buffer{}
+		code, err := defaultActionConfig().gronStream(in, out, optMonochrome|optConcat)
+
+		if code != exitOK {
+			t.Errorf("want exitOK; have %d", code)
+		}
+		if err != nil {
+			t.Errorf("want nil error; have %s", err)
+		}
+
+		if !reflect.DeepEqual(want, out.Bytes()) {
+			t.Logf("want: %s", want)
+			t.Logf("have: %s", out.Bytes())
+			t.Errorf("gronned %s does not match %s", c.inFile, c.outFile)
+		}
+	}
+
+}
+
+func TestGronStreamSkipInvalid(t *testing.T) {
+	in := strings.NewReader("{\"a\":1}\nnope\n{\"b\":2}\n")
+
+	log := &bytes.Buffer{}
+	c := defaultActionConfig()
+	c.invalidLog = log
+
+	out := &bytes.Buffer{}
+	code, err := c.gronStream(in, out, optMonochrome|optSkipInvalid)
+	if code != exitPartial {
+		t.Errorf("want exitPartial; have %d", code)
+	}
+	if err == nil {
+		t.Errorf("want non-nil error; have nil")
+	}
+
+	// The skipped line still uses up an index
+	want := strings.Join([]string{
+		`json = [];`,
+		`json[0] = {};`,
+		`json[0].a = 1;`,
+		`json[2] = {};`,
+		`json[2].b = 2;`,
+		``,
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("want:\n%s\nhave:\n%s", want, out.String())
+	}
+
+	wantLog := "<input>:2: byte 9: invalid character 'o' in literal null (expecting 'u')\n"
+	if log.String() != wantLog {
+		t.Errorf("want log %q; have %q", wantLog, log.String())
+	}
+}
+
 func TestLargeGronStream(t *testing.T) {
 	cases := []struct {
 		inFile  string
@@ -109,7 +185,7 @@ func TestLargeGronStream(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := gronStream(in, out, optMonochrome)
+		code, err := defaultActionConfig().gronStream(in, out, optMonochrome)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -158,7 +234,7 @@ func TestUngron(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := ungron(in, out, optMonochrome)
+		code, err := defaultActionConfig().ungron(in, out, optMonochrome)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -182,6 +258,48 @@ func TestUngron(t *testing.T) {
 	}
 }
 
+func TestUngronInvalid(t *testing.T) {
+	cases := []struct {
+		inFile   string
+		opts     int
+		wantErrs int
+	}{
+		{"testdata/invalid-value.gron", optMonochrome, 1},
+		{"testdata/invalid-type-mismatch.gron", optMonochrome, 1},
+		{"testdata/invalid-many.gron", optMonochrome, 1},
+		{"testdata/invalid-many.gron", optMonochrome | optKeepGoing, 3},
+	}
+
+	for _, c := range cases {
+		in, err := os.Open(c.inFile)
+		if err != nil {
+			t.Fatalf("failed to open input file: %s", err)
+		}
+
+		out := &bytes.Buffer{}
+		code, err := defaultActionConfig().ungron(in, out, c.opts)
+
+		if code != exitParseStatements {
+			t.Errorf("want exitParseStatements for %s; have %d", c.inFile, code)
+		}
+		if err == nil {
+			t.Fatalf("want non-nil error for %s; have nil", c.inFile)
+		}
+
+		haveErrs := 1
+		if errs, ok := err.(parseErrors); ok {
+			haveErrs = len(errs)
+		}
+		if haveErrs != c.wantErrs {
+			t.Errorf("want %d errors for %s; have %d (%s)", c.wantErrs, c.inFile, haveErrs, err)
+		}
+
+		if !strings.HasPrefix(err.Error(), c.inFile+":") {
+			t.Errorf("want error for %s to start with the filename; have %s", c.inFile, err)
+		}
+	}
+}
+
 func TestGronJ(t *testing.T) {
 	cases := []struct {
 		inFile  string
@@ -205,7 +323,7 @@ func TestGronJ(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := gron(in, out, optMonochrome|optJSON)
+		code, err := defaultActionConfig().gron(in, out, optMonochrome|optJSON)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -244,7 +362,7 @@ func TestGronStreamJ(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := gronStream(in, out, optMonochrome|optJSON)
+		code, err := defaultActionConfig().gronStream(in, out, optMonochrome|optJSON)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -262,6 +380,51 @@ func TestGronStreamJ(t *testing.T) {
 
 }
 
+func TestNumberRoundTrip(t *testing.T) {
+	rawJSON, err := ioutil.ReadFile("testdata/big-numbers.json")
+	if err != nil {
+		t.Fatalf("failed to open JSON file: %s", err)
+	}
+	statements, err := ioutil.ReadFile("testdata/big-numbers.gron")
+	if err != nil {
+		t.Fatalf("failed to open gron file: %s", err)
+	}
+	jsonStatements, err := ioutil.ReadFile("testdata/big-numbers.jgron")
+	if err != nil {
+		t.Fatalf("failed to open jgron file: %s", err)
+	}
+
+	// Every number should come out exactly as it went in,
+	// so the outputs are compared as text
+	cfg := defaultActionConfig()
+	cases := []struct {
+		name   string
+		action actionFn
+		opts   int
+		in     []byte
+		want   []byte
+	}{
+		{"gron", cfg.gron, optMonochrome, rawJSON, statements},
+		{"gron --json", cfg.gron, optMonochrome | optJSON, rawJSON, jsonStatements},
+		{"ungron", cfg.ungron, optMonochrome, statements, rawJSON},
+		{"ungron --json", cfg.ungron, optMonochrome | optJSON, jsonStatements, rawJSON},
+	}
+
+	for _, c := range cases {
+		out := &bytes.Buffer{}
+		code, err := c.action(bytes.NewReader(c.in), out, c.opts)
+		if code != exitOK {
+			t.Errorf("%s: want exitOK; have %d", c.name, code)
+		}
+		if err != nil {
+			t.Errorf("%s: want nil error; have %s", c.name, err)
+		}
+		if !bytes.Equal(out.Bytes(), c.want) {
+			t.Errorf("%s: want:\n%s\nhave:\n%s", c.name, c.want, out.Bytes())
+		}
+	}
+}
+
 func TestUngronJ(t *testing.T) {
 	cases := []struct {
 		inFile  string
@@ -291,7 +454,7 @@ func TestUngronJ(t *testing.T) {
 		}
 
 		out := &bytes.Buffer{}
-		code, err := ungron(in, out, optMonochrome|optJSON)
+		code, err := defaultActionConfig().ungron(in, out, optMonochrome|optJSON)
 
 		if code != exitOK {
 			t.Errorf("want exitOK; have %d", code)
@@ -328,7 +491,7 @@ func BenchmarkBigJSON(b *testing.B) {
 			b.Fatalf("failed to rewind input: %s", err)
 		}
 
-		_, err := gron(in, out, optMonochrome|optNoSort)
+		_, err := defaultActionConfig().gron(in, out, optMonochrome|optNoSort)
 		if err != nil {
 			b.Fatalf("failed to gron: %s", err)
 		}
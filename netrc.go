@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A netrcMachine holds the credentials for one host in a .netrc file
+type netrcMachine struct {
+	name     string // The host name, or "" for the default entry
+	login    string
+	password string
+}
+
+// netrc is the parsed contents of a .netrc file
+type netrc []netrcMachine
+
+// defaultNetrcFile returns the path of the user's .netrc file
+func defaultNetrcFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// readNetrc reads and parses a .netrc file
+func readNetrc(filename string) (netrc, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// A .netrc file is a list of whitespace separated tokens, except
+	// that macro definitions run until the next blank line
+	var n netrc
+	var m *netrcMachine
+	inMacro := false
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				break
+			}
+
+			// Every keyword apart from 'default' has a value after it
+			next := ""
+			if i+1 < len(fields) {
+				next = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				n = append(n, netrcMachine{name: next})
+				m = &n[len(n)-1]
+				i++
+			case "default":
+				n = append(n, netrcMachine{})
+				m = &n[len(n)-1]
+			case "login":
+				if m != nil {
+					m.login = next
+				}
+				i++
+			case "password":
+				if m != nil {
+					m.password = next
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return n, sc.Err()
+}
+
+// lookup returns the credentials for a host, falling back
+// to the default entry if there's one
+func (n netrc) lookup(host string) (netrcMachine, bool) {
+	for _, m := range n {
+		if m.name != "" && strings.EqualFold(m.name, host) {
+			return m, true
+		}
+	}
+	for _, m := range n {
+		if m.name == "" {
+			return m, true
+		}
+	}
+	return netrcMachine{}, false
+}
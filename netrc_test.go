@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadNetrc(t *testing.T) {
+	name := filepath.Join(t.TempDir(), ".netrc")
+	err := os.WriteFile(name, []byte(`
+# A comment
+machine api.example.com login tom password s3cret
+machine other.example.com
+	login sam
+	password hunter2
+macdef init
+machine evil.example.com login nope password nope
+
+default login anon password guest
+`), 0600)
+	if err != nil {
+		t.Fatalf("failed to write test file: %s", err)
+	}
+
+	n, err := readNetrc(name)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+
+	cases := []struct {
+		host     string
+		login    string
+		password string
+	}{
+		{"api.example.com", "tom", "s3cret"},
+		{"API.example.com", "tom", "s3cret"},
+		{"other.example.com", "sam", "hunter2"},
+
+		// Inside the macro definition, so it's the default
+		{"evil.example.com", "anon", "guest"},
+		{"unknown.example.com", "anon", "guest"},
+	}
+
+	for _, c := range cases {
+		m, ok := n.lookup(c.host)
+		if !ok {
+			t.Errorf("want credentials for %s; have none", c.host)
+			continue
+		}
+		if m.login != c.login || m.password != c.password {
+			t.Errorf("want %s:%s for %s; have %s:%s", c.login, c.password, c.host, m.login, m.password)
+		}
+	}
+}
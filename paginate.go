@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// A pageConfig says how to find each page of a paginated API
+type pageConfig struct {
+	next        statement // The path to the next page's URL or cursor; nil to use Link headers
+	cursorParam string    // The query parameter to put the cursor in
+	items       statement // The path to the array of items on each page; nil for the page itself
+	flatten     bool      // Write each item instead of each page
+	maxPages    int       // The most pages to fetch; 0 for no limit
+}
+
+// parsePath parses a path like json.meta.cursor or json.data[0]["next page"]
+func parsePath(path string) (statement, error) {
+	s := newLexer(path).lex()
+	if len(s) == 0 || s[0].typ != typBare {
+		return nil, fmt.Errorf("invalid path %q; must start with a name like json", path)
+	}
+	for _, t := range s {
+		switch t.typ {
+		case typBare, typNumericKey, typQuotedKey, typDot, typLBrace, typRBrace:
+		default:
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return s, nil
+}
+
+// lookupPath finds the raw JSON at a path in some raw JSON. The first
+// part of the path is the root, so it's ignored
+func lookupPath(data json.RawMessage, path statement) (json.RawMessage, bool) {
+	for _, t := range path[1:] {
+		switch t.typ {
+		case typBare, typQuotedKey:
+			key := t.text
+			if t.typ == typQuotedKey {
+				if err := json.Unmarshal([]byte(t.text), &key); err != nil {
+					return nil, false
+				}
+			}
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(data, &m); err != nil {
+				return nil, false
+			}
+			v, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			data = v
+
+		case typNumericKey:
+			i, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, false
+			}
+			var a []json.RawMessage
+			if err := json.Unmarshal(data, &a); err != nil || i >= len(a) {
+				return nil, false
+			}
+			data = a[i]
+		}
+	}
+	return data, true
+}
+
+// paginate returns a reader for the pages of a paginated API, or for the
+// items on them if they're being flattened. Every page or item is written
+// on its own line, so it can be read with --concat
+func paginate(start string, c requestConfig, p pageConfig) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(fetchPages(start, c, p, pw))
+	}()
+	return pr
+}
+
+// fetchPages fetches each page in turn, starting with the start URL
+// and stopping when there's no next page, or at the maximum number
+// of pages. A URL that has already been fetched also stops it, so a
+// broken API can't make it go round in circles. The next page's URL
+// comes from the server, so credentials and headers given as options
+// are only sent with it if it's on the same host as the start URL
+func fetchPages(start string, c requestConfig, p pageConfig, w io.Writer) error {
+	seen := make(map[string]bool)
+	warned := make(map[string]bool)
+	next := start
+
+	for page := 1; ; page++ {
+		seen[next] = true
+		pc := c
+		if host, same := sameOrigin(start, next); !same {
+			pc = c.withoutCredentials()
+			if !warned[host] && c.hasCredentials() {
+				warn("not sending credentials or headers to %s for page %d; it isn't the host of the first page", host, page)
+				warned[host] = true
+			}
+		}
+		resp, err := fetchURL(next, pc)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read page %d (%s): %s", page, next, err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("page %d (%s) isn't valid JSON", page, next)
+		}
+
+		if err := writePage(w, data, p); err != nil {
+			return fmt.Errorf("page %d (%s): %s", page, next, err)
+		}
+
+		next, err = nextPage(next, start, resp.Header, data, p)
+		if err != nil {
+			return fmt.Errorf("page %d: %s", page, err)
+		}
+		if next == "" || seen[next] {
+			return nil
+		}
+		if p.maxPages > 0 && page >= p.maxPages {
+			warn("stopped after %d pages; there are more (see --max-pages)", page)
+			return nil
+		}
+	}
+}
+
+// writePage writes a page, or each of the items on it, as compact
+// JSON on its own line. The raw JSON is used so that numbers and
+// duplicate keys are kept exactly as they were
+func writePage(w io.Writer, data []byte, p pageConfig) error {
+	values := []json.RawMessage{data}
+	if p.flatten {
+		items := json.RawMessage(data)
+		if p.items != nil {
+			var ok bool
+			items, ok = lookupPath(items, p.items)
+			if !ok {
+				return fmt.Errorf("no items at %s", p.items)
+			}
+		}
+		values = nil
+		if err := json.Unmarshal(items, &values); err != nil {
+			return fmt.Errorf("the items to flatten aren't an array")
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, v := range values {
+		buf.Reset()
+		if err := json.Compact(&buf, v); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextPage returns the URL of the page after current, or "" if there
+// isn't one. It's either the value at the next path, which is a URL or
+// a cursor to put in the query string of the start URL, or the rel="next"
+// URL in the Link header
+func nextPage(current, start string, h http.Header, data []byte, p pageConfig) (string, error) {
+	if p.next == nil {
+		return resolveURL(current, linkNext(h.Values("Link")))
+	}
+
+	raw, ok := lookupPath(data, p.next)
+	if !ok {
+		return "", nil
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+
+	var cursor string
+	switch vv := v.(type) {
+	case string:
+		cursor = vv
+	case json.Number:
+		cursor = vv.String()
+	case nil, bool:
+		// null and false are common ways to say there are no more pages
+		return "", nil
+	default:
+		return "", fmt.Errorf("the value at %s isn't a URL or cursor", p.next)
+	}
+	if cursor == "" {
+		return "", nil
+	}
+
+	if p.cursorParam == "" {
+		if _, isNumber := v.(json.Number); isNumber {
+			return "", fmt.Errorf("the value at %s is a number, not a URL; use --cursor-param to say where it goes", p.next)
+		}
+		return resolveURL(current, cursor)
+	}
+
+	u, err := url.Parse(start)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(p.cursorParam, cursor)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// sameOrigin says if two URLs have the same scheme and host, and returns
+// the scheme and host of the second one. URLs that can't be parsed are
+// never the same
+func sameOrigin(a, b string) (string, bool) {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return b, false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return b, false
+	}
+	origin := ub.Scheme + "://" + ub.Host
+	return origin, strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// resolveURL resolves a possibly relative reference against a base URL
+func resolveURL(base, ref string) (string, error) {
+	if ref == "" {
+		return "", nil
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page URL %q: %s", ref, err)
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// linkNext returns the rel="next" URL from RFC 5988 Link headers;
+// e.g. Link: <https://api.example.com/items?page=2>; rel="next"
+func linkNext(headers []string) string {
+	for _, h := range headers {
+		for h != "" {
+			start := strings.IndexByte(h, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(h[start:], '>')
+			if end < 0 {
+				break
+			}
+			link := h[start+1 : start+end]
+			h = h[start+end+1:]
+
+			// The parameters run until the next link
+			params := h
+			if i := strings.IndexByte(h, '<'); i >= 0 {
+				params = h[:i]
+			}
+			for _, param := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				v = strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), ",")), `"`)
+				for _, rel := range strings.Fields(v) {
+					if strings.EqualFold(rel, "next") {
+						return link
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLinkNext(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    string
+	}{
+		{nil, ""},
+		{[]string{`<https://a.com/?page=2>; rel="next"`}, "https://a.com/?page=2"},
+		{[]string{`<https://a.com/?page=1>; rel="prev", <https://a.com/?page=3>; rel="next"`}, "https://a.com/?page=3"},
+		{[]string{`<https://a.com/?page=9>; rel="last"`, `</items?page=2>; rel=next`}, "/items?page=2"},
+		{[]string{`<https://a.com/?a=1,2>; title="x"; rel="prefetch next"`}, "https://a.com/?a=1,2"},
+		{[]string{`<https://a.com/?page=9>; rel="last"`}, ""},
+	}
+
+	for _, test := range tests {
+		have := linkNext(test.headers)
+		if have != test.want {
+			t.Errorf("want %q for linkNext(%q); have %q", test.want, test.headers, have)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	data := []byte(`{"meta": {"next": "abc", "pages": [1, 2]}, "a b": true}`)
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"json.meta.next", `"abc"`, true},
+		{"json.meta.pages[1]", `2`, true},
+		{`json["a b"]`, `true`, true},
+		{"json.meta.pages[2]", ``, false},
+		{"json.missing", ``, false},
+		{"json.meta.next.deeper", ``, false},
+	}
+
+	for _, test := range tests {
+		path, err := parsePath(test.path)
+		if err != nil {
+			t.Fatalf("want nil error from parsePath(%s); have %s", test.path, err)
+		}
+		have, ok := lookupPath(data, path)
+		if ok != test.ok || string(have) != test.want {
+			t.Errorf("want %s, %t for %s; have %s, %t", test.want, test.ok, test.path, have, ok)
+		}
+	}
+
+	for _, path := range []string{"", "[0]", "json.a = 1", "json["} {
+		if _, err := parsePath(path); err == nil {
+			t.Errorf("want error from parsePath(%q); have nil", path)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	pages := []string{
+		`{"data":[1,2],"next":"/?page=2","cursor":"c2"}`,
+		`{"data":[3],"next":"/?page=3","cursor":"c3"}`,
+		`{"data":[4,5],"next":null,"cursor":""}`,
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 1
+		switch {
+		case r.URL.Query().Get("page") != "":
+			fmt.Sscan(r.URL.Query().Get("page"), &n)
+		case r.URL.Query().Get("cursor") != "":
+			fmt.Sscanf(r.URL.Query().Get("cursor"), "c%d", &n)
+		}
+		if n < len(pages) {
+			w.Header().Add("Link", fmt.Sprintf(`</?page=%d>; rel="next"`, n+1))
+		}
+		fmt.Fprint(w, pages[n-1])
+	}))
+	defer ts.Close()
+
+	path := func(s string) statement {
+		p, err := parsePath(s)
+		if err != nil {
+			t.Fatalf("want nil error from parsePath(%s); have %s", s, err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name string
+		p    pageConfig
+		want string
+	}{
+		{
+			"link header",
+			pageConfig{},
+			pages[0] + "\n" + pages[1] + "\n" + pages[2] + "\n",
+		},
+		{
+			"next path",
+			pageConfig{next: path("json.next"), flatten: true, items: path("json.data")},
+			"1\n2\n3\n4\n5\n",
+		},
+		{
+			"cursor",
+			pageConfig{next: path("json.cursor"), cursorParam: "cursor", flatten: true, items: path("json.data")},
+			"1\n2\n3\n4\n5\n",
+		},
+		{
+			"max pages",
+			pageConfig{flatten: true, items: path("json.data"), maxPages: 2},
+			"1\n2\n3\n",
+		},
+	}
+
+	for _, test := range tests {
+		have, err := io.ReadAll(paginate(ts.URL, requestConfig{}, test.p))
+		if err != nil {
+			t.Fatalf("%s: want nil error; have %s", test.name, err)
+		}
+		if string(have) != test.want {
+			t.Errorf("%s: want %q; have %q", test.name, test.want, have)
+		}
+	}
+}
+
+func TestPaginateOtherHost(t *testing.T) {
+	var other http.Header
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = r.Header.Clone()
+		fmt.Fprint(w, `{"page":3}`)
+	}))
+	defer ts2.Close()
+
+	var auth []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Add("Link", `</?page=2>; rel="next"`)
+			fmt.Fprint(w, `{"page":1}`)
+			return
+		}
+		w.Header().Add("Link", fmt.Sprintf(`<%s/>; rel="next"`, ts2.URL))
+		fmt.Fprint(w, `{"page":2}`)
+	}))
+	defer ts.Close()
+
+	c := requestConfig{
+		token:  "secret",
+		header: http.Header{"X-Api-Key": []string{"key"}},
+	}
+	have, err := io.ReadAll(paginate(ts.URL, c, pageConfig{}))
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	if want := "{\"page\":1}\n{\"page\":2}\n{\"page\":3}\n"; string(have) != want {
+		t.Errorf("want %q; have %q", want, have)
+	}
+
+	// Every page on the first host gets the credentials...
+	if len(auth) != 2 || auth[0] != "Bearer secret" || auth[1] != "Bearer secret" {
+		t.Errorf("want the token sent for both pages on the first host; have %q", auth)
+	}
+
+	// ...but the page on the other host doesn't
+	if other == nil {
+		t.Fatalf("want the page on the other host to be fetched")
+	}
+	if v := other.Get("Authorization"); v != "" {
+		t.Errorf("want no Authorization header for the other host; have %q", v)
+	}
+	if v := other.Get("X-Api-Key"); v != "" {
+		t.Errorf("want no X-Api-Key header for the other host; have %q", v)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// A requestTiming records when each part of making a request happened
+type requestTiming struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// trace returns a copy of a request that records its timing. Reused
+// connections don't need DNS, connecting or TLS, so those stay zero
+func (t *requestTiming) trace(req *http.Request) *http.Request {
+	// The hooks can be called from other goroutines
+	// when more than one address is tried at once
+	at := func(when *time.Time, first bool) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if !first || when.IsZero() {
+			*when = time.Now()
+		}
+	}
+
+	t.start = time.Now()
+	ct := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { at(&t.dnsStart, true) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { at(&t.dnsDone, false) },
+		ConnectStart:         func(string, string) { at(&t.connectStart, true) },
+		ConnectDone:          func(string, string, error) { at(&t.connectDone, false) },
+		TLSHandshakeStart:    func() { at(&t.tlsStart, true) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { at(&t.tlsDone, false) },
+		GotFirstResponseByte: func() { at(&t.firstByte, false) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct))
+}
+
+// milliseconds returns how many milliseconds there are
+// between two times, or 0 if either of them is missing
+func milliseconds(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return math.Round(float64(to.Sub(from))/float64(time.Microsecond)) / 1000
+}
+
+// timings returns how long each part of the request took in milliseconds.
+// first_byte and total are measured from the start of the request
+func (t *requestTiming) timings(end time.Time) map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return map[string]float64{
+		"dns_ms":        milliseconds(t.dnsStart, t.dnsDone),
+		"connect_ms":    milliseconds(t.connectStart, t.connectDone),
+		"tls_ms":        milliseconds(t.tlsStart, t.tlsDone),
+		"first_byte_ms": milliseconds(t.start, t.firstByte),
+		"total_ms":      milliseconds(t.start, end),
+	}
+}
+
+// fetchResponse fetches a URL and returns the JSON for its response, as
+// made by withMetadata. The point is to see the status and headers, so
+// responses that aren't 2xx are used instead of being an error
+func fetchResponse(url string, c requestConfig) (io.Reader, int, error) {
+	c.ignoreStatus = true
+	resp, err := fetchURL(url, c)
+	if err != nil {
+		return nil, exitFetchURL, err
+	}
+	r, err := withMetadata(resp)
+	if err != nil {
+		return nil, exitReadInput, err
+	}
+	return r, exitOK, nil
+}
+
+// withMetadata returns the JSON for a response with its final URL, status,
+// headers and timing alongside the body; e.g. json.status = 200; json.body.id = 1;
+// A body that isn't JSON, like an HTML error page, is included as a string
+func withMetadata(resp *response) (io.Reader, error) {
+	body, err := io.ReadAll(resp.body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now()
+
+	raw := json.RawMessage(bytes.TrimSpace(body))
+	switch {
+	case len(raw) == 0:
+		raw = json.RawMessage("null")
+	case !json.Valid(raw):
+		raw, err = json.Marshal(string(body))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Headers with more than one value become arrays
+	headers := make(map[string]interface{}, len(resp.Header))
+	for name, values := range resp.Header {
+		if len(values) == 1 {
+			headers[name] = values[0]
+		} else {
+			headers[name] = values
+		}
+	}
+
+	out, err := json.Marshal(struct {
+		URL     string                 `json:"url"`
+		Status  int                    `json:"status"`
+		Proto   string                 `json:"proto"`
+		Headers map[string]interface{} `json:"headers"`
+		Timing  map[string]float64     `json:"timing"`
+		Body    json.RawMessage        `json:"body"`
+	}{
+		URL:     resp.Request.URL.String(),
+		Status:  resp.StatusCode,
+		Proto:   resp.Proto,
+		Headers: headers,
+		Timing:  resp.timing.timings(end),
+		Body:    raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(out), nil
+}
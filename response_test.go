@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		if r.URL.Path == "/html" {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<h1>Bad Gateway</h1>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer srv.Close()
+
+	// Responses that aren't 2xx aren't an error without ignoreStatus
+	c := requestConfig{
+		proxyURL: undefinedProxy,
+		noProxy:  undefinedProxy,
+	}
+
+	cases := []struct {
+		path string
+		want []string
+	}{
+		{"/", []string{
+			`json.body.id = 1;`,
+			`json.status = 200;`,
+			`json.headers["Content-Type"] = "application/json";`,
+			`json.headers["Set-Cookie"][1] = "b=2";`,
+			`json.url = "` + srv.URL + `/";`,
+		}},
+		{"/html", []string{
+			`json.body = "<h1>Bad Gateway</h1>";`,
+			`json.status = 502;`,
+			`json.headers["Content-Type"] = "text/html";`,
+		}},
+	}
+
+	for _, test := range cases {
+		r, code, err := fetchResponse(srv.URL+test.path, c)
+		if code != exitOK || err != nil {
+			t.Fatalf("want exitOK and nil error for %s; have %d and %v", test.path, code, err)
+		}
+
+		out := &bytes.Buffer{}
+		code, err = defaultActionConfig().gron(r, out, optMonochrome)
+		if code != exitOK || err != nil {
+			t.Fatalf("want exitOK and nil error from gron; have %d and %v", code, err)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want+"\n") {
+				t.Errorf("want %s in the output for %s; have:\n%s", want, test.path, out)
+			}
+		}
+		if !strings.Contains(out.String(), "json.timing.total_ms = ") {
+			t.Errorf("want timing in the output for %s; have:\n%s", test.path, out)
+		}
+	}
+}
+
+func TestTimings(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	timing := &requestTiming{
+		start:        start,
+		connectStart: start,
+		connectDone:  start.Add(1500 * time.Microsecond),
+		firstByte:    start.Add(20 * time.Millisecond),
+	}
+
+	have := timing.timings(start.Add(25 * time.Millisecond))
+	want := map[string]float64{
+		"dns_ms":        0,
+		"connect_ms":    1.5,
+		"tls_ms":        0,
+		"first_byte_ms": 20,
+		"total_ms":      25,
+	}
+	for k, v := range want {
+		if have[k] != v {
+			t.Errorf("want %s = %v; have %v", k, v, have[k])
+		}
+	}
+}
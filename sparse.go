@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An arrayInfo holds what's known about an array while ungronning
+type arrayInfo struct {
+	indexes map[int]bool // The indexes that have been seen
+	length  int          // One more than the highest index seen
+	uses    []arrayUse   // Where each index was seen
+}
+
+// An arrayUse is where an index of an array was seen: the
+// statement it's in and the position of its token
+type arrayUse struct {
+	idx  int
+	stmt int
+	tok  int
+}
+
+// hasGaps returns true if any indexes are missing from the array
+func (a *arrayInfo) hasGaps() bool {
+	return len(a.indexes) != a.length
+}
+
+// pathKey returns a string that identifies the path made up of the
+// first n tokens of a statement. Bare words and quoted keys for the
+// same key produce the same result so that json.a and json["a"]
+// are considered to be the same thing
+func pathKey(s statement, n int) string {
+	var b strings.Builder
+	for _, t := range s[:n] {
+		switch t.typ {
+		case typBare:
+			b.WriteString(quoteString(t.text))
+		case typQuotedKey:
+			var k string
+			if err := json.Unmarshal([]byte(t.text), &k); err == nil {
+				b.WriteString(quoteString(k))
+			} else {
+				b.WriteString(t.text)
+			}
+		case typNumericKey:
+			b.WriteString(t.text)
+		default:
+			continue
+		}
+		b.WriteByte('/')
+	}
+	return b.String()
+}
+
+// equalsIndex returns the index of the equals token in a statement,
+// or -1 if there isn't one
+func equalsIndex(s statement) int {
+	for i, t := range s {
+		if t.typ == typEquals {
+			return i
+		}
+	}
+	return -1
+}
+
+// sparseArrays deals with arrays that have missing indexes before the
+// statements are ungronned. By default the only thing it does is check
+// that no array would need more than maxGap nulls to fill in its missing
+// indexes, so that a statement like json[1000000] = 1; can't allocate a
+// huge array; a maxGap of 0 means there's no limit. With optSparseCompact
+// the indexes of any array with gaps are renumbered so that the elements
+// that are present are kept in order, and with optSparseObject those
+// arrays are turned into objects keyed by index instead.
+//
+// fn is called with the index of any statement that's rejected, and that
+// statement is replaced with an empty one so that it's skipped later
+func (ss statements) sparseArrays(opts int, maxGap int, fn func(int, error) error) error {
+	compact := opts&optSparseCompact > 0
+	object := opts&optSparseObject > 0
+	arrays := make(map[string]*arrayInfo)
+
+	for i, s := range ss {
+		for j, t := range s {
+			if t.typ != typNumericKey {
+				continue
+			}
+
+			// Invalid keys are reported when the statement is ungronned
+			idx, err := strconv.Atoi(t.text)
+			if err != nil {
+				break
+			}
+
+			key := pathKey(s, j)
+			a, ok := arrays[key]
+			if !ok {
+				a = &arrayInfo{indexes: make(map[int]bool)}
+				arrays[key] = a
+			}
+
+			a.indexes[idx] = true
+			a.uses = append(a.uses, arrayUse{idx, i, j})
+			if idx >= a.length {
+				a.length = idx + 1
+			}
+		}
+	}
+
+	// The gaps only matter when the arrays are going to be padded
+	if !compact && !object {
+		if maxGap > 0 {
+			return ss.rejectGaps(arrays, maxGap, fn)
+		}
+		return nil
+	}
+
+	// Work out the new key for every index of the arrays with gaps
+	newKeys := make(map[string]map[int]token)
+	for key, a := range arrays {
+		if !a.hasGaps() {
+			continue
+		}
+
+		keys := make(map[int]token, len(a.indexes))
+		if compact {
+			sorted := make([]int, 0, len(a.indexes))
+			for idx := range a.indexes {
+				sorted = append(sorted, idx)
+			}
+			sort.Ints(sorted)
+			for n, idx := range sorted {
+				keys[idx] = token{strconv.Itoa(n), typNumericKey}
+			}
+		} else {
+			for idx := range a.indexes {
+				keys[idx] = token{quoteString(strconv.Itoa(idx)), typQuotedKey}
+			}
+		}
+		newKeys[key] = keys
+	}
+
+	for i, s := range ss {
+		// When arrays become objects, any empty array
+		// value for one of them needs to be an empty
+		// object instead or the two won't merge
+		if eq := equalsIndex(s); object && eq > -1 && eq+1 < len(s) && s[eq+1].typ == typEmptyArray {
+			if _, ok := newKeys[pathKey(s, eq)]; ok {
+				s[eq+1] = token{"{}", typEmptyObject}
+			}
+		}
+
+		// Work backwards so that the path leading up to each
+		// key is still the original path when it's looked up
+		for j := len(s) - 1; j >= 0; j-- {
+			if s[j].typ != typNumericKey {
+				continue
+			}
+			keys, ok := newKeys[pathKey(s, j)]
+			if !ok {
+				continue
+			}
+			idx, err := strconv.Atoi(s[j].text)
+			if err != nil {
+				continue
+			}
+			ss[i][j] = keys[idx]
+		}
+	}
+
+	return nil
+}
+
+// rejectGaps rejects the statements for array indexes that would need more
+// than maxGap nulls before them. Every index has to be known first so
+// that the order of the statements doesn't matter. fn is called for the
+// rejected statements in order, and they're replaced with empty ones
+func (ss statements) rejectGaps(arrays map[string]*arrayInfo, maxGap int, fn func(int, error) error) error {
+	rejected := make(map[int]errToken)
+	for _, a := range arrays {
+		if a.length-len(a.indexes) <= maxGap {
+			continue
+		}
+
+		// The number of missing indexes before each index
+		sorted := make([]int, 0, len(a.indexes))
+		for idx := range a.indexes {
+			sorted = append(sorted, idx)
+		}
+		sort.Ints(sorted)
+		missing := make(map[int]int, len(sorted))
+		for n, idx := range sorted {
+			missing[idx] = idx - n
+		}
+
+		for _, u := range a.uses {
+			if missing[u.idx] <= maxGap {
+				continue
+			}
+			// Only the first bad index in a statement is reported
+			if prev, ok := rejected[u.stmt]; ok && prev.index < u.tok {
+				continue
+			}
+			rejected[u.stmt] = errToken{
+				index:    u.tok,
+				msg:      fmt.Sprintf("array index %d has %d missing indexes before it", u.idx, missing[u.idx]),
+				expected: fmt.Sprintf("no more than %d; see --sparse and --max-array-gap", maxGap),
+			}
+		}
+	}
+
+	stmts := make([]int, 0, len(rejected))
+	for i := range rejected {
+		stmts = append(stmts, i)
+	}
+	sort.Ints(stmts)
+	for _, i := range stmts {
+		if err := fn(i, rejected[i]); err != nil {
+			return err
+		}
+		ss[i] = nil
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSparseArrays(t *testing.T) {
+	in := []string{
+		`json.a[3] = 1;`,
+		`json.a[7] = {};`,
+		`json.a[7].x[2] = "y";`,
+		`json.b = [];`,
+		`json.b[0] = true;`,
+		`json.b[1] = false;`,
+		`json["c"] = [];`,
+		`json.c[5] = null;`,
+	}
+
+	cases := []struct {
+		opts int
+		want interface{}
+	}{
+		{0, map[string]interface{}{
+			"json": map[string]interface{}{
+				"a": []interface{}{
+					3: 1.0,
+					7: map[string]interface{}{
+						"x": []interface{}{2: "y"},
+					},
+				},
+				"b": []interface{}{true, false},
+				"c": []interface{}{5: nil},
+			},
+		}},
+		{optSparseCompact, map[string]interface{}{
+			"json": map[string]interface{}{
+				"a": []interface{}{
+					1.0,
+					map[string]interface{}{
+						"x": []interface{}{"y"},
+					},
+				},
+				"b": []interface{}{true, false},
+				"c": []interface{}{nil},
+			},
+		}},
+		{optSparseObject, map[string]interface{}{
+			"json": map[string]interface{}{
+				"a": map[string]interface{}{
+					"3": 1.0,
+					"7": map[string]interface{}{
+						"x": map[string]interface{}{"2": "y"},
+					},
+				},
+				"b": []interface{}{true, false},
+				"c": map[string]interface{}{"5": nil},
+			},
+		}},
+	}
+
+	for _, c := range cases {
+		ss := statementsFromStringSlice(in)
+		err := ss.sparseArrays(c.opts, 0, func(i int, err error) error {
+			return err
+		})
+		if err != nil {
+			t.Fatalf("want nil error from sparseArrays; have %s", err)
+		}
+
+		have, err := ss.toInterface()
+		if err != nil {
+			t.Fatalf("want nil error from toInterface; have %s", err)
+		}
+
+		// Numbers are json.Number when ungronned, so normalise
+		// them to make the comparison easier
+		have = normaliseNumbers(have)
+
+		if !reflect.DeepEqual(have, c.want) {
+			t.Logf("have: %#v", have)
+			t.Logf("want: %#v", c.want)
+			t.Errorf("unexpected result for opts %d", c.opts)
+		}
+	}
+}
+
+func TestSparseArraysMaxGap(t *testing.T) {
+	ss := statementsFromStringSlice([]string{
+		`json.a[0] = 1;`,
+		`json.a[5] = 1;`,
+		`json.a[100] = 1;`,
+		`json.b[11] = 1;`,
+	})
+
+	var rejected []int
+	err := ss.sparseArrays(0, 10, func(i int, err error) error {
+		rejected = append(rejected, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("want nil error from sparseArrays; have %s", err)
+	}
+
+	want := []int{2, 3}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Errorf("want statements %v to be rejected; have %v", want, rejected)
+	}
+	if ss[2] != nil || ss[3] != nil {
+		t.Errorf("want rejected statements to be emptied")
+	}
+
+	// The order of the statements doesn't matter,
+	// only how many indexes are really missing
+	in := make([]string, 0, 20)
+	for i := 19; i >= 0; i-- {
+		in = append(in, fmt.Sprintf("json[%d] = %d;", i, i))
+	}
+	ss = statementsFromStringSlice(in)
+	err = ss.sparseArrays(0, 10, func(i int, err error) error {
+		t.Errorf("want no rejected statements for a reversed array; have %s", err)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("want nil error from sparseArrays; have %s", err)
+	}
+
+	// The gap doesn't matter if the array isn't padded
+	ss = statementsFromStringSlice([]string{`json.a[100] = 1;`})
+	err = ss.sparseArrays(optSparseCompact, 10, func(i int, err error) error {
+		t.Errorf("want no rejected statements; have %s", err)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("want nil error from sparseArrays; have %s", err)
+	}
+}
+
+// normaliseNumbers converts json.Numbers in a datastructure to float64s
+func normaliseNumbers(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range vv {
+			vv[k] = normaliseNumbers(sub)
+		}
+	case []interface{}:
+		for k, sub := range vv {
+			vv[k] = normaliseNumbers(sub)
+		}
+	case json.Number:
+		f, _ := vv.Float64()
+		return f
+	}
+	return v
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -74,6 +76,15 @@ func (s statement) jsonify() (statement, error) {
 	// allocation of j with capacity len(s)+1 will allow us to carry
 	// through without reallocation.
 	j := make(statement, 0, len(s)+1)
+
+	// A comment, like the marker for embedded JSON, becomes a
+	// third element so that ungron can still see it
+	var comment string
+	if len(s) > 0 && s[len(s)-1].typ == typComment {
+		comment = strings.TrimSpace(strings.TrimPrefix(s[len(s)-1].text, "//"))
+		s = s[:len(s)-1]
+	}
+
 	if len(s) < 4 || s[0].typ != typBare || s[len(s)-3].typ != typEquals ||
 		s[len(s)-1].typ != typSemi {
 		return nil, errors.New("non-assignment statement")
@@ -97,6 +108,10 @@ func (s statement) jsonify() (statement, error) {
 	j = append(j, token{"]", typLBrace})
 	j = append(j, token{",", typComma})
 	j = append(j, s[len(s)-2])
+	if comment != "" {
+		j = append(j, token{",", typComma})
+		j = append(j, token{quoteString(comment), typString})
+	}
 	j = append(j, token{"]", typLBrace})
 
 	return j, nil
@@ -174,6 +189,14 @@ func statementFromStringMaker(str string) (statement, error) {
 	return statementFromString(str), nil
 }
 
+// statementmaker variant of statementFromString that lexes in strict
+// mode, so malformed values result in an error token
+func statementFromStringStrictMaker(str string) (statement, error) {
+	l := newLexer(str)
+	l.strict = true
+	return l.lex(), nil
+}
+
 // statementFromJson returns statement encoded by
 // JSON specification
 func statementFromJSONSpec(str string) (statement, error) {
@@ -184,12 +207,25 @@ func statementFromJSONSpec(str string) (statement, error) {
 	var t tokenTyp
 	var nstr string
 	var nbuf []byte
+	var comment string
 
-	err := json.Unmarshal([]byte(str), &a)
+	// Numbers are decoded as json.Number so that their
+	// exact text is kept, rather than going via float64
+	d := json.NewDecoder(strings.NewReader(str))
+	d.UseNumber()
+	err := d.Decode(&a)
 	if err != nil {
 		return nil, err
 	}
-	if len(a) != 2 {
+	switch len(a) {
+	case 2:
+	case 3:
+		// A comment, like the marker for embedded JSON
+		comment, ok = a[2].(string)
+		if !ok {
+			goto out
+		}
+	default:
 		goto out
 	}
 
@@ -208,13 +244,12 @@ func statementFromJSONSpec(str string) (statement, error) {
 		switch e := e.(type) {
 		case string:
 			s = append(s, token{quoteString(e), typQuotedKey})
-		case float64:
-			nbuf, err = json.Marshal(e)
-			if err != nil {
-				return nil, errors.Wrap(err, "JSON internal error")
+		case json.Number:
+			if _, err := strconv.Atoi(e.String()); err != nil {
+				ok = false
+				goto out
 			}
-			nstr = string(nbuf)
-			s = append(s, token{nstr, typNumericKey})
+			s = append(s, token{e.String(), typNumericKey})
 		default:
 			ok = false
 			goto out
@@ -231,7 +266,7 @@ func statementFromJSONSpec(str string) (statement, error) {
 		} else {
 			t = typFalse
 		}
-	case float64:
+	case json.Number:
 		t = typNumber
 	case string:
 		t = typString
@@ -263,6 +298,9 @@ func statementFromJSONSpec(str string) (statement, error) {
 	s = append(s, token{nstr, t})
 
 	s = append(s, token{";", typSemi})
+	if comment != "" {
+		s = append(s, token{"// " + comment, typComment})
+	}
 
 out:
 	if !ok {
@@ -273,10 +311,22 @@ out:
 
 // ungron turns statements into a proper datastructure
 func (ss statements) toInterface() (interface{}, error) {
+	return ss.toInterfaceFunc(func(i int, err error) error {
+		return errors.Wrapf(err, "ungron failed for `%s`", ss[i])
+	})
+}
 
-	// Get all the individually parsed statements
+// toInterfaceFunc is like toInterface, but calls fn with the index of
+// any statement that fails to parse or merge so that the caller can
+// decide how to handle it. Processing stops if fn returns an error,
+// otherwise the offending statement is skipped
+func (ss statements) toInterfaceFunc(fn func(int, error) error) (interface{}, error) {
+
+	// Get all the individually parsed statements, along with the
+	// index of the statement each one came from
 	var parsed []interface{}
-	for _, s := range ss {
+	var indexes []int
+	for i, s := range ss {
 		u, err := ungronTokens(s)
 
 		switch err.(type) {
@@ -285,10 +335,14 @@ func (ss statements) toInterface() (interface{}, error) {
 		case errRecoverable:
 			continue
 		default:
-			return nil, errors.Wrapf(err, "ungron failed for `%s`", s)
+			if err := fn(i, err); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		parsed = append(parsed, u)
+		indexes = append(indexes, i)
 	}
 
 	if len(parsed) == 0 {
@@ -296,10 +350,14 @@ func (ss statements) toInterface() (interface{}, error) {
 	}
 
 	merged := parsed[0]
-	for _, p := range parsed[1:] {
+	for j, p := range parsed[1:] {
 		m, err := recursiveMerge(merged, p)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to merge statements")
+			err = errors.Wrap(err, "failed to merge statements")
+			if err := fn(indexes[j+1], err); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		merged = m
 	}
@@ -307,6 +365,16 @@ func (ss statements) toInterface() (interface{}, error) {
 
 }
 
+// sort sorts the statements. With optDuplicates the sort is stable so that
+// every occurrence of a duplicate key stays in the order it appeared in
+func (ss statements) sort(opts int) {
+	if opts&optDuplicates > 0 {
+		sort.Stable(ss)
+		return
+	}
+	sort.Sort(ss)
+}
+
 // Less compares two statements for sort.Sort
 // Implements a natural sort to keep array indexes in order
 func (ss statements) Less(a, b int) bool {
@@ -324,8 +392,14 @@ func (ss statements) Less(a, b int) bool {
 			return false
 		}
 
-		// The tokens match, so just carry on
+		// The tokens match, so just carry on. If they're both the
+		// equals then the paths are the same, which only happens for
+		// duplicate keys; they're equal so that a stable sort keeps
+		// them in the order they appeared in, so the last one still wins
 		if ss[a][i] == ss[b][i] {
+			if ss[a][i].typ == typEquals {
+				return false
+			}
 			continue
 		}
 
@@ -364,12 +438,32 @@ func (ss statements) Less(a, b int) bool {
 		return ta.text < tb.text
 	}
 
-	// We have two numbers to compare so turn them into json.Number
-	// for comparison
-	na, _ := json.Number(ta.text).Float64()
-	nb, _ := json.Number(tb.text).Float64()
-	return na < nb
+	return compareNumbers(ta.text, tb.text) < 0
+}
+
+// compareNumbers compares two JSON numbers exactly, returning -1, 0 or
+// +1. Comparing them as float64s is enough unless they're so close that
+// they round to the same float64, e.g. 64-bit IDs, in which case they're
+// compared as exact fractions. Numbers that are out of range for a float64
+// are compared as text rather than risk a huge allocation
+func compareNumbers(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	case errA != nil || errB != nil:
+		return strings.Compare(a, b)
+	}
 
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if !okA || !okB {
+		return strings.Compare(a, b)
+	}
+	return ra.Cmp(rb)
 }
 
 // Contains searches the statements for a given statement
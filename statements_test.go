@@ -97,6 +97,33 @@ func TestStatementsSorting(t *testing.T) {
 	}
 }
 
+func TestCompareNumbers(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1", "2", -1},
+		{"2", "1.5", 1},
+		{"1E+2", "100", 0},
+		{"-0.0", "0", 0},
+
+		// These are the same as float64s
+		{"12345678901234567890", "12345678901234567891", -1},
+		{"9007199254740993", "9007199254740992", 1},
+		{"0.10000000000000000555", "0.1000000000000000055511151231257827", -1},
+
+		// Out of range numbers are compared as text
+		{"1e400", "2e400", -1},
+	}
+
+	for _, c := range cases {
+		have := compareNumbers(c.a, c.b)
+		if have != c.want {
+			t.Errorf("want %d for compareNumbers(%s, %s); have %d", c.want, c.a, c.b, have)
+		}
+	}
+}
+
 func BenchmarkStatementsLess(b *testing.B) {
 	ss := statementsFromStringSlice([]string{
 		`json.c[21][2] = true;`,
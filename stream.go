@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// setScannerBuffer gives a scanner a buffer that grows up to max bytes,
+// or with no limit if max is 0. The scanner never uses less than the
+// initial size, so that has to be capped at the limit too
+func setScannerBuffer(sc *bufio.Scanner, max int) {
+	if max <= 0 {
+		max = math.MaxInt
+	}
+	sc.Buffer(make([]byte, 0, min(64*1024, max)), max)
+}
+
+// tooLongError replaces bufio.ErrTooLong with an error that says which
+// line or record went over the limit; other errors are returned as-is
+func tooLongError(err error, what string, n int, max int) error {
+	if err != bufio.ErrTooLong {
+		return err
+	}
+	return fmt.Errorf("%s %d is longer than the maximum of %d bytes; see --max-line-size", what, n, max)
+}
+
+// A lineScanner is a bufio.Scanner that keeps track of the number and
+// byte offset of each line, so that it can say which one was too long,
+// and so that invalid lines can be reported with optSkipInvalid
+type lineScanner struct {
+	*bufio.Scanner
+	line   int
+	offset int64 // The byte offset of the start of the current line
+	pos    int64 // The byte offset of the end of the current line
+	max    int   // The longest line allowed; 0 for no limit
+}
+
+// newLineScanner returns a lineScanner that reads from r, allowing
+// lines of up to max bytes, or of any length if max is 0
+func newLineScanner(r io.Reader, max int) *lineScanner {
+	s := &lineScanner{Scanner: bufio.NewScanner(r), max: max}
+	setScannerBuffer(s.Scanner, max)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, tok, err := bufio.ScanLines(data, atEOF)
+		if tok != nil {
+			s.offset = s.pos
+		}
+		s.pos += int64(advance)
+		return advance, tok, err
+	})
+	return s
+}
+
+// Scan reads the next line from the input
+func (s *lineScanner) Scan() bool {
+	if !s.Scanner.Scan() {
+		return false
+	}
+	s.line++
+	return true
+}
+
+// Err returns the first error that occurred, if any
+func (s *lineScanner) Err() error {
+	return tooLongError(s.Scanner.Err(), "line", s.line+1, s.max)
+}
+
+// recordSeparator is the ASCII RS character that starts each
+// record in an RFC 7464 JSON text sequence
+const recordSeparator = 0x1E
+
+// A recordScanner splits a stream of input into separate JSON records.
+// It has the same methods as a bufio.Scanner so that one can be used
+// for the default one-record-per-line stream mode
+type recordScanner interface {
+	Scan() bool
+	Bytes() []byte
+	Err() error
+}
+
+// newRecordScanner returns the appropriate recordScanner for the options;
+// optConcat means any sequence of JSON values, otherwise it's one per line.
+// Lines and records can be up to max bytes long, or any length if it's 0
+func newRecordScanner(r io.Reader, opts int, max int) recordScanner {
+	if opts&optConcat > 0 {
+		return newConcatScanner(r)
+	}
+	if opts&optSeq > 0 {
+		return newSeqScanner(r, max)
+	}
+	return newLineScanner(r, max)
+}
+
+// A record is a single JSON value read from a stream, along
+// with where it was found so that errors can be reported
+type record struct {
+	index  int    // The index of the record; i.e. json[index]
+	data   []byte // The raw JSON
+	line   int    // The line number, when reading line by line
+	offset int64  // The byte offset of the line, when reading line by line
+}
+
+// readRecord returns a copy of the most recent record from
+// a recordScanner, so that it can outlive the next Scan
+func readRecord(sc recordScanner, index int) record {
+	rec := record{
+		index: index,
+		data:  append([]byte(nil), sc.Bytes()...),
+	}
+	if ls, ok := sc.(*lineScanner); ok {
+		rec.line = ls.line
+		rec.offset = ls.offset
+	}
+	return rec
+}
+
+// invalidRecord describes a record that couldn't be gronned. For line
+// by line input that's the line number and the byte offset of the
+// error in the whole input, otherwise it's the number of the record
+func invalidRecord(name string, rec record, err error) string {
+	if rec.line == 0 {
+		return fmt.Sprintf("%s: record %d: %s", name, rec.index+1, err)
+	}
+
+	offset := rec.offset + int64(len(rec.data))
+	if se, ok := err.(*json.SyntaxError); ok {
+		offset = rec.offset + se.Offset - 1
+	}
+	return fmt.Sprintf("%s:%d: byte %d: %s", name, rec.line, offset, err)
+}
+
+// formatRecord turns a record into statements, returning the output
+// for all of them, one per line. Any duplicate keys are found too with
+// optDuplicates or optLint, and strings are decoded with the decoders
+func formatRecord(rec record, prefix statement, opts int, ds valueDecoders, conv func(statement) string) formattedRecord {
+	f := formattedRecord{rec: rec}
+
+	var ss statements
+	if opts&(optDuplicates|optLint) > 0 {
+		ss, f.dups, f.err = statementsFromJSONTokens(rec.data, prefix.withNumericKey(rec.index), opts&optDuplicates > 0)
+	} else {
+		ss, f.err = statementsFromJSON(bytes.NewReader(rec.data), prefix.withNumericKey(rec.index))
+	}
+	if f.err != nil {
+		return f
+	}
+
+	// Offsets are for the whole input, like they are for invalid records
+	for _, d := range f.dups {
+		for i := range d.offsets {
+			d.offsets[i] += rec.offset
+		}
+	}
+
+	if e := expanders(opts, ds); len(e) > 0 {
+		ss = ss.expandStrings(e...)
+	}
+
+	// Go's maps do not have well-defined ordering, but we want a consistent
+	// output for a given input, so we must sort the statements
+	if opts&optNoSort == 0 {
+		ss.sort(opts)
+	}
+
+	out := &bytes.Buffer{}
+	for _, s := range ss {
+		if opts&optJSON > 0 {
+			s, f.err = s.jsonify()
+			if f.err != nil {
+				return f
+			}
+		}
+		out.WriteString(conv(s))
+		out.WriteByte('\n')
+	}
+	f.out = out.Bytes()
+	return f
+}
+
+// A formattedRecord is the output of formatting a record,
+// or the error that stopped it from being formatted
+type formattedRecord struct {
+	rec  record
+	out  []byte
+	dups []duplicateKey
+	err  error
+}
+
+// recordName returns the name of a record for warnings; the
+// line number for line by line input, or the record number
+func recordName(name string, rec record) string {
+	if rec.line == 0 {
+		return fmt.Sprintf("%s: record %d", name, rec.index+1)
+	}
+	return fmt.Sprintf("%s:%d", name, rec.line)
+}
+
+// formatRecords reads the records from sc and formats each of them with fn
+// using a pool of workers. The results are sent on the returned channel in
+// the same order the records were read, and the channel is closed when
+// there are no more. Only a few records per worker are read ahead of the
+// one that's next to be sent, so memory use is bounded no matter how big
+// the input is. Closing stop makes it give up early
+func formatRecords(sc recordScanner, workers int, stop <-chan struct{}, fn func(record) formattedRecord) <-chan formattedRecord {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// A job's result channel is queued on pending at the same time as the
+	// job is handed out, so results can be waited for in the right order
+	type job struct {
+		rec    record
+		result chan formattedRecord
+	}
+	jobs := make(chan job)
+	pending := make(chan chan formattedRecord, workers*2)
+
+	for n := 0; n < workers; n++ {
+		go func() {
+			for j := range jobs {
+				j.result <- fn(j.rec)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for i := 0; sc.Scan(); i++ {
+			j := job{readRecord(sc, i), make(chan formattedRecord, 1)}
+			select {
+			case pending <- j.result:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	out := make(chan formattedRecord)
+	go func() {
+		defer close(out)
+		for result := range pending {
+			var f formattedRecord
+			select {
+			case f = <-result:
+			case <-stop:
+				return
+			}
+			select {
+			case out <- f:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// A concatScanner is a recordScanner for a stream of concatenated JSON
+// values, regardless of any whitespace between or inside them; e.g.
+// pretty-printed objects one after the other
+type concatScanner struct {
+	d   *json.Decoder
+	raw json.RawMessage
+	err error
+}
+
+// newConcatScanner returns a concatScanner that reads from r
+func newConcatScanner(r io.Reader) *concatScanner {
+	return &concatScanner{d: json.NewDecoder(r)}
+}
+
+// Scan reads the next JSON value from the input, returning false
+// when there are no more or when an error occurred
+func (s *concatScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	s.raw = s.raw[:0]
+	err := s.d.Decode(&s.raw)
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		s.err = err
+		return false
+	}
+	return true
+}
+
+// Bytes returns the raw bytes of the most recent JSON value
+func (s *concatScanner) Bytes() []byte {
+	return s.raw
+}
+
+// Err returns the first error that occurred, if any
+func (s *concatScanner) Err() error {
+	return s.err
+}
+
+// A seqScanner is a recordScanner for RFC 7464 JSON text sequences,
+// where each record starts with an RS character. As the RFC describes,
+// records that have been truncated or are otherwise invalid are
+// dropped so that the rest of the sequence can still be read
+type seqScanner struct {
+	sc      *bufio.Scanner
+	rec     []byte
+	n       int
+	dropped int
+	max     int
+}
+
+// newSeqScanner returns a seqScanner that reads from r, allowing
+// records of up to max bytes, or of any length if max is 0
+func newSeqScanner(r io.Reader, max int) *seqScanner {
+	sc := bufio.NewScanner(r)
+	setScannerBuffer(sc, max)
+	sc.Split(splitSeq)
+	return &seqScanner{sc: sc, max: max}
+}
+
+// splitSeq is a bufio.SplitFunc that splits input on RS characters
+func splitSeq(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, recordSeparator); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// validSeqRecord returns true if a record from a JSON text sequence is
+// complete. Top-level numbers, true, false and null could have been
+// truncated without making them invalid JSON, so the RFC says they
+// must be followed by whitespace to be sure they're complete
+func validSeqRecord(rec []byte) bool {
+	if !json.Valid(rec) {
+		return false
+	}
+
+	trimmed := bytes.TrimLeft(rec, " \t\r\n")
+	switch trimmed[0] {
+	case '{', '[', '"':
+		return true
+	}
+	last := rec[len(rec)-1]
+	return last == ' ' || last == '\t' || last == '\r' || last == '\n'
+}
+
+// Scan reads the next complete record from the input, skipping
+// any that are empty and dropping any that are invalid
+func (s *seqScanner) Scan() bool {
+	for s.sc.Scan() {
+		rec := s.sc.Bytes()
+
+		// Multiple RS characters in a row are allowed,
+		// and so is nothing before the first one
+		if len(bytes.TrimSpace(rec)) == 0 {
+			continue
+		}
+		s.n++
+
+		if !validSeqRecord(rec) {
+			s.dropped++
+			continue
+		}
+		s.rec = rec
+		return true
+	}
+	return false
+}
+
+// Bytes returns the most recent record
+func (s *seqScanner) Bytes() []byte {
+	return s.rec
+}
+
+// Err returns the first error that occurred, if any
+func (s *seqScanner) Err() error {
+	return tooLongError(s.sc.Err(), "record", s.n+1, s.max)
+}
+
+// Dropped returns the number of records that were dropped
+// because they were truncated or otherwise invalid
+func (s *seqScanner) Dropped() int {
+	return s.dropped
+}
+
+// recordIndex checks that a statement is part of a record in a stream;
+// e.g. json[4].foo = "bar"; and returns the record's index along with
+// the statement without the index (json.foo = "bar";). The statement
+// establishing the top-level array (json = [];) has an index of -1
+func recordIndex(s statement) (int, statement, bool) {
+	if len(s) > 1 && s[0].typ == typBare && s[1].typ == typEquals {
+		return -1, nil, true
+	}
+
+	if len(s) < 4 || s[0].typ != typBare || s[1].typ != typLBrace ||
+		s[2].typ != typNumericKey || s[3].typ != typRBrace {
+		return 0, nil, false
+	}
+
+	idx, err := strconv.Atoi(s[2].text)
+	if err != nil {
+		return 0, nil, false
+	}
+
+	trimmed := make(statement, 0, len(s)-3)
+	trimmed = append(trimmed, s[0])
+	trimmed = append(trimmed, s[4:]...)
+	return idx, trimmed, true
+}
+
+// ungronRecords is like ungron, but expects the statements for a stream
+// of records like those from gronStream (json[0].foo = "bar";), and writes
+// each record as a single line of JSON (optNDJSON) or as an RFC 7464 JSON
+// text sequence (optSeq). Indexes with no statements are skipped rather
+// than being written as null
+func (c actionConfig) ungronRecords(r io.Reader, w io.Writer, opts int) (int, error) {
+	u := newUngronReader(r, opts, c)
+
+	// Statements are grouped by record, with the record
+	// index removed so that each can be ungronned alone
+	batches := make(map[int]*ungronBatch)
+	for u.scan() {
+		if len(u.stmt) == 0 || u.stmt[0].typ == typIgnored {
+			continue
+		}
+
+		idx, s, ok := recordIndex(u.stmt)
+		if !ok {
+			err := errToken{index: 1, msg: "statement is not part of a record", expected: "json[n]"}
+			if err := u.report(u.line, u.text, err); err != nil {
+				return exitParseStatements, err
+			}
+			continue
+		}
+		if idx < 0 {
+			continue
+		}
+
+		b, exists := batches[idx]
+		if !exists {
+			b = &ungronBatch{trimmed: 3}
+			batches[idx] = b
+		}
+		b.add(s, u.line, u.text)
+	}
+	if u.err != nil {
+		return u.code, u.err
+	}
+
+	indexes := make([]int, 0, len(batches))
+	for idx := range batches {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	for _, idx := range indexes {
+		code, err := u.writeBatch(w, batches[idx])
+		if err != nil {
+			return code, err
+		}
+	}
+
+	return u.finish()
+}
+
+// ungronStream is like ungronRecords, but expects the statements to be
+// sorted by record index, as they are in the output of gronStream. Each
+// record is written as soon as a statement for the next one is read, so
+// it works on streams that never end. Statements for a record that has
+// already been written are reported as being out of order
+func (c actionConfig) ungronStream(r io.Reader, w io.Writer, opts int) (int, error) {
+	u := newUngronReader(r, opts, c)
+
+	current := -1
+	var b *ungronBatch
+	for u.scan() {
+		if len(u.stmt) == 0 || u.stmt[0].typ == typIgnored {
+			continue
+		}
+
+		idx, s, ok := recordIndex(u.stmt)
+		if !ok {
+			err := errToken{index: 1, msg: "statement is not part of a record", expected: "json[n]"}
+			if err := u.report(u.line, u.text, err); err != nil {
+				return exitParseStatements, err
+			}
+			continue
+		}
+		if idx < 0 {
+			continue
+		}
+
+		if idx < current {
+			err := errToken{
+				index:    2,
+				msg:      fmt.Sprintf("record %d is out of order", idx),
+				expected: fmt.Sprintf("an index of at least %d; the input must be sorted", current),
+			}
+			if err := u.report(u.line, u.text, err); err != nil {
+				return exitParseStatements, err
+			}
+			continue
+		}
+
+		if idx > current && b != nil {
+			code, err := u.writeBatch(w, b)
+			if err != nil {
+				return code, err
+			}
+			b = nil
+		}
+
+		if b == nil {
+			b = &ungronBatch{trimmed: 3}
+			current = idx
+		}
+		b.add(s, u.line, u.text)
+	}
+	if u.err != nil {
+		return u.code, u.err
+	}
+
+	if b != nil {
+		code, err := u.writeBatch(w, b)
+		if err != nil {
+			return code, err
+		}
+	}
+
+	return u.finish()
+}
+
+// writeBatch ungrons the statements for a single record and writes it
+// with writeRecord. Nothing is written if every statement was invalid
+func (u *ungronReader) writeBatch(w io.Writer, b *ungronBatch) (int, error) {
+	merged, err := u.ungron(b)
+	if err != nil {
+		return exitParseStatements, err
+	}
+	if merged == nil {
+		return exitOK, nil
+	}
+
+	if m, ok := merged.(map[string]interface{}); ok && len(m) == 1 {
+		for _, v := range m {
+			merged = v
+		}
+	}
+
+	return writeRecord(w, merged, u.opts)
+}
+
+// writeRecord writes v as a single line of compact JSON, preceded
+// by an RS character if optSeq is set
+func writeRecord(w io.Writer, v interface{}, opts int) (int, error) {
+	out := &bytes.Buffer{}
+	if opts&optSeq > 0 {
+		out.WriteByte(recordSeparator)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	if err != nil {
+		return exitJSONEncode, fmt.Errorf("failed to convert record to JSON: %s", err)
+	}
+
+	_, err = w.Write(out.Bytes())
+	if err != nil {
+		return exitWriteFile, fmt.Errorf("failed to write record: %s", err)
+	}
+	return exitOK, nil
+}
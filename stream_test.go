@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeqScanner(t *testing.T) {
+	in := "\x1e{\"a\":1}\n" + // fine
+		"\x1e{\"b\":\n" + // truncated object
+		"\x1e123" + // truncated number
+		"\x1e\"s\"\n" + // fine
+		"\x1e\x1e[1,2]\n" + // multiple RS are allowed
+		"\x1etrue\n" // fine
+
+	sc := newSeqScanner(strings.NewReader(in), 0)
+	var have []string
+	for sc.Scan() {
+		have = append(have, strings.TrimSpace(string(sc.Bytes())))
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+
+	want := []string{`{"a":1}`, `"s"`, `[1,2]`, `true`}
+	if strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Errorf("want records %v; have %v", want, have)
+	}
+	if sc.Dropped() != 2 {
+		t.Errorf("want 2 dropped records; have %d", sc.Dropped())
+	}
+}
+
+func TestUngronRecords(t *testing.T) {
+	in := strings.Join([]string{
+		`json = [];`,
+		`json[0] = {};`,
+		`json[0].one = 1;`,
+		`json[0].three = [];`,
+		`json[0].three[1] = 2;`,
+		`--`,
+		`json[12] = "twelve";`,
+		`json[3] = {};`,
+		`json[3]["a b"] = true;`,
+	}, "\n")
+
+	cases := []struct {
+		opts int
+		want string
+	}{
+		{optNDJSON, "{\"one\":1,\"three\":[null,2]}\n{\"a b\":true}\n\"twelve\"\n"},
+		{optSeq, "\x1e{\"one\":1,\"three\":[null,2]}\n\x1e{\"a b\":true}\n\x1e\"twelve\"\n"},
+	}
+
+	for _, c := range cases {
+		out := &bytes.Buffer{}
+		code, err := defaultActionConfig().ungronRecords(strings.NewReader(in), out, c.opts)
+		if code != exitOK {
+			t.Errorf("want exitOK; have %d", code)
+		}
+		if err != nil {
+			t.Errorf("want nil error; have %s", err)
+		}
+		if out.String() != c.want {
+			t.Errorf("want %q; have %q", c.want, out.String())
+		}
+	}
+}
+
+func TestUngronRecordsInvalid(t *testing.T) {
+	in := strings.Join([]string{
+		`json[0] = 1;`,
+		`json.foo = 1;`,
+		`json[1] = tru;`,
+	}, "\n")
+
+	out := &bytes.Buffer{}
+	code, err := defaultActionConfig().ungronRecords(strings.NewReader(in), out, optNDJSON|optKeepGoing)
+	if code != exitParseStatements {
+		t.Errorf("want exitParseStatements; have %d", code)
+	}
+
+	errs, ok := err.(parseErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("want 2 parse errors; have %#v", err)
+	}
+
+	// Columns should be for the original statements, even
+	// though the record index is removed before ungronning
+	if errs[0].line != 2 || errs[0].col != 5 {
+		t.Errorf("want first error at 2:5; have %d:%d", errs[0].line, errs[0].col)
+	}
+	if errs[1].line != 3 || errs[1].col != 11 {
+		t.Errorf("want second error at 3:11; have %d:%d", errs[1].line, errs[1].col)
+	}
+
+	if out.String() != "1\n" {
+		t.Errorf("want valid records to be written; have %q", out.String())
+	}
+}
+
+func TestUngronStream(t *testing.T) {
+	in := strings.Join([]string{
+		`json = [];`,
+		`json[0] = {};`,
+		`json[0].one = 1;`,
+		`json[2] = {};`,
+		`json[2].two = 2;`,
+		`json[1].three = 3;`,
+		`json[2].four = 4;`,
+	}, "\n")
+
+	out := &bytes.Buffer{}
+	code, err := defaultActionConfig().ungronStream(strings.NewReader(in), out, optNDJSON|optKeepGoing)
+	if code != exitParseStatements {
+		t.Errorf("want exitParseStatements; have %d", code)
+	}
+
+	// The record that's out of order is skipped, but the
+	// statements either side of it are still part of record 2
+	errs, ok := err.(parseErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("want 1 parse error; have %#v", err)
+	}
+	if errs[0].line != 6 || errs[0].col != 6 {
+		t.Errorf("want error at 6:6; have %d:%d", errs[0].line, errs[0].col)
+	}
+
+	want := "{\"one\":1}\n{\"four\":4,\"two\":2}\n"
+	if out.String() != want {
+		t.Errorf("want %q; have %q", want, out.String())
+	}
+}
+
+func TestLineScannerMaxSize(t *testing.T) {
+	in := "short\n" + strings.Repeat("x", 100) + "\n"
+
+	sc := newLineScanner(strings.NewReader(in), 10)
+	for sc.Scan() {
+	}
+	want := "line 2 is longer than the maximum of 10 bytes; see --max-line-size"
+	if sc.Err() == nil || sc.Err().Error() != want {
+		t.Errorf("want error %q; have %v", want, sc.Err())
+	}
+
+	// With no limit, lines can be longer than bufio.MaxScanTokenSize
+	long := strings.Repeat("x", bufio.MaxScanTokenSize*2)
+	sc = newLineScanner(strings.NewReader(long), 0)
+	if !sc.Scan() || len(sc.Bytes()) != len(long) {
+		t.Errorf("want one long line; have error %v", sc.Err())
+	}
+}
+
+func TestFormatRecordsOrder(t *testing.T) {
+	var in strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&in, "%d\n", i)
+	}
+
+	// Later records are quicker to format, so they'd
+	// finish first if the order wasn't being kept
+	fn := func(rec record) formattedRecord {
+		time.Sleep(time.Duration(500-rec.index) * time.Microsecond)
+		return formattedRecord{rec: rec, out: rec.data}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	i := 0
+	for f := range formatRecords(newLineScanner(strings.NewReader(in.String()), 0), 8, stop, fn) {
+		if string(f.out) != strconv.Itoa(i) {
+			t.Fatalf("want record %d; have %s", i, f.out)
+		}
+		i++
+	}
+	if i != 500 {
+		t.Errorf("want 500 records; have %d", i)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// tlsVersions are the values allowed for --tls-min
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion parses a TLS version like 1.2
+func parseTLSVersion(v string) (uint16, error) {
+	version, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(v), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q; must be 1.0, 1.1, 1.2 or 1.3", v)
+	}
+	return version, nil
+}
+
+// tlsConfig returns the TLS config for fetching URLs. A CA bundle
+// replaces the system's CAs, just like curl's --cacert does
+func (c requestConfig) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		InsecureSkipVerify: c.insecure,
+		MinVersion:         c.tlsMin,
+		ServerName:         c.serverName,
+	}
+
+	if c.caCert != "" {
+		pem, err := os.ReadFile(c.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %s", err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", c.caCert)
+		}
+	}
+
+	if c.cert != "" {
+		// The key can be in the same file as the certificate
+		key := c.key
+		if key == "" {
+			key = c.cert
+		}
+		cert, err := tls.LoadX509KeyPair(c.cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// parseResolve parses host overrides in the host:port:address form
+// used by curl's --resolve; e.g. api.example.com:443:10.0.0.5. They're
+// returned as a map of host:port to the address to connect to instead
+func parseResolve(overrides []string) (map[string]string, error) {
+	r := make(map[string]string)
+	for _, o := range overrides {
+		parts := strings.SplitN(o, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid --resolve %q; must be in the form host:port:address", o)
+		}
+		host, port := strings.ToLower(parts[0]), parts[1]
+		addr := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid --resolve %q; %s isn't an IP address", o, addr)
+		}
+		r[net.JoinHostPort(host, port)] = net.JoinHostPort(addr, port)
+	}
+	return r, nil
+}
+
+// dialContext returns a dial function that connects to the addresses
+// given with --resolve instead of looking the hosts up. The URL keeps
+// its host, so it's still used for SNI and checking the certificate
+func dialContext(resolve map[string]string) func(context.Context, string, string) (net.Conn, error) {
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if override, ok := resolve[strings.ToLower(addr)]; ok {
+			addr = override
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes PEM blocks to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	var b []byte
+	for _, block := range blocks {
+		b = append(b, pem.EncodeToMemory(block)...)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatalf("failed to write %s: %s", name, err)
+	}
+	return path
+}
+
+// clientCert makes a self-signed client certificate, returning
+// it and the PEM blocks for it and its key
+func clientCert(t *testing.T) (*x509.Certificate, *pem.Block, *pem.Block) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gron test client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %s", err)
+	}
+	return cert, &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+}
+
+func TestFetchURLTLS(t *testing.T) {
+	dir := t.TempDir()
+	client, certBlock, keyBlock := clientCert(t)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(client)
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MaxVersion: tls.VersionTLS12,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	caCert := writePEM(t, dir, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	cert := writePEM(t, dir, "cert.pem", certBlock)
+	key := writePEM(t, dir, "key.pem", keyBlock)
+	combined := writePEM(t, dir, "combined.pem", certBlock, keyBlock)
+
+	// The test server's certificate is for example.com as well as 127.0.0.1
+	u, _ := url.Parse(srv.URL)
+	resolve, err := parseResolve([]string{"example.com:" + u.Port() + ":127.0.0.1"})
+	if err != nil {
+		t.Fatalf("want nil error from parseResolve; have %s", err)
+	}
+
+	base := requestConfig{proxyURL: undefinedProxy, noProxy: undefinedProxy}
+
+	cases := []struct {
+		name string
+		url  string
+		c    func(c requestConfig) requestConfig
+		ok   bool
+	}{
+		{"no CA", srv.URL, func(c requestConfig) requestConfig {
+			c.cert, c.key = cert, key
+			return c
+		}, false},
+		{"no client cert", srv.URL, func(c requestConfig) requestConfig {
+			c.caCert = caCert
+			return c
+		}, false},
+		{"CA and client cert", srv.URL, func(c requestConfig) requestConfig {
+			c.caCert, c.cert, c.key = caCert, cert, key
+			return c
+		}, true},
+		{"combined cert and key", srv.URL, func(c requestConfig) requestConfig {
+			c.caCert, c.cert = caCert, combined
+			return c
+		}, true},
+		{"resolve", "https://example.com:" + u.Port(), func(c requestConfig) requestConfig {
+			c.caCert, c.cert, c.key, c.resolve = caCert, cert, key, resolve
+			return c
+		}, true},
+		{"wrong SNI", srv.URL, func(c requestConfig) requestConfig {
+			c.caCert, c.cert, c.key, c.serverName = caCert, cert, key, "gron.test"
+			return c
+		}, false},
+		{"minimum version too high", srv.URL, func(c requestConfig) requestConfig {
+			c.caCert, c.cert, c.key, c.tlsMin = caCert, cert, key, tls.VersionTLS13
+			return c
+		}, false},
+	}
+
+	for _, test := range cases {
+		_, err := getURL(test.url, test.c(base))
+		if test.ok && err != nil {
+			t.Errorf("%s: want nil error; have %s", test.name, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: want an error; have nil", test.name)
+		}
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	cases := []struct {
+		in   string
+		key  string
+		want string
+		ok   bool
+	}{
+		{"api.example.com:443:10.0.0.5", "api.example.com:443", "10.0.0.5:443", true},
+		{"API.example.com:8443:[::1]", "api.example.com:8443", "[::1]:8443", true},
+		{"api.example.com:443", "", "", false},
+		{"api.example.com:443:not-an-ip", "", "", false},
+	}
+
+	for _, test := range cases {
+		have, err := parseResolve([]string{test.in})
+		if !test.ok {
+			if err == nil {
+				t.Errorf("want error for %q; have nil", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("want nil error for %q; have %s", test.in, err)
+		}
+		if have[test.key] != test.want {
+			t.Errorf("want %s for %s; have %v", test.want, test.key, have)
+		}
+	}
+}
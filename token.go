@@ -46,6 +46,10 @@ const (
 	// Ignored token
 	typIgnored
 
+	// A comment at the end of a statement; like the
+	// '// duplicate key, 1 of 2' in json.a = 1; // duplicate key, 1 of 2
+	typComment
+
 	// Error token
 	typError
 )
@@ -94,6 +98,9 @@ func (t token) format() string {
 	if t.typ == typEquals {
 		return " " + t.text + " "
 	}
+	if t.typ == typComment {
+		return " " + t.text
+	}
 	return t.text
 }
 
@@ -103,6 +110,9 @@ func (t token) formatColor() string {
 	if t.typ == typEquals {
 		text = " " + text + " "
 	}
+	if t.typ == typComment {
+		text = " " + text
+	}
 	fn, ok := sprintFns[t.typ]
 	if ok {
 		return fn(text)
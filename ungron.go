@@ -2,7 +2,8 @@
 // back into JSON. The expected input grammar is:
 //
 //   Input ::= '--'* Statement (Statement | '--')*
-//   Statement ::= Path Space* "=" Space* Value ";" "\n"
+//   Statement ::= Path Space* "=" Space* Value ";" Comment? "\n"
+//   Comment ::= " //" [^\n]*
 //   Path ::= (BareWord) ("." BareWord | ("[" Key "]"))*
 //   Value ::= String | Number | "true" | "false" | "null" | "[]" | "{}"
 //   BareWord ::= (UnicodeLu | UnicodeLl | UnicodeLm | UnicodeLo | UnicodeNl | '$' | '_') (UnicodeLu | UnicodeLl | UnicodeLm | UnicodeLo | UnicodeNl | UnicodeMn | UnicodeMc | UnicodeNd | UnicodePc | '$' | '_')*
@@ -15,6 +16,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
@@ -34,6 +36,130 @@ func (e errRecoverable) Error() string {
 	return e.msg
 }
 
+// A parseError describes an input line that could not be ungronned,
+// along with where it was found so the user can go and fix it
+type parseError struct {
+	name     string // The name of the input; e.g. a filename
+	line     int    // The 1-based line number in the input
+	col      int    // The 1-based column (in runes) in the line
+	text     string // The raw text of the line
+	msg      string // What went wrong
+	expected string // What was expected instead, if known
+}
+
+// maxExcerpt is the maximum number of runes either side of the
+// error position that are shown in a parseError's excerpt
+const maxExcerpt = 40
+
+func (e parseError) Error() string {
+	msg := e.msg
+	if e.expected != "" {
+		msg = fmt.Sprintf("%s, expected %s", msg, e.expected)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s\n%s", e.name, e.line, e.col, msg, e.excerpt())
+}
+
+// excerpt returns the text of the line that the error occurred
+// on, with a caret underneath to show the error position. Very
+// long lines are trimmed to show only the area around the error
+func (e parseError) excerpt() string {
+	runes := []rune(e.text)
+	pos := e.col - 1
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+
+	start, end := 0, len(runes)
+	prefix, suffix := "", ""
+	if pos > maxExcerpt {
+		start = pos - maxExcerpt
+		prefix = "..."
+	}
+	if end-pos > maxExcerpt {
+		end = pos + maxExcerpt
+		suffix = "..."
+	}
+
+	// Keep any tabs in the padding so that the caret
+	// lines up with the text above it
+	pad := []rune(prefix)
+	for _, r := range runes[start:pos] {
+		if r == '\t' {
+			pad = append(pad, '\t')
+		} else {
+			pad = append(pad, ' ')
+		}
+	}
+
+	return fmt.Sprintf("    %s%s%s\n    %s^", prefix, string(runes[start:end]), suffix, string(pad))
+}
+
+// parseErrors is a list of parseErrors, used to report every
+// problem with the input in one go
+type parseErrors []parseError
+
+func (es parseErrors) Error() string {
+	out := make([]string, 0, len(es)+1)
+	for _, e := range es {
+		out = append(out, e.Error())
+	}
+	if len(es) == 1 {
+		out = append(out, "1 invalid statement")
+	} else {
+		out = append(out, fmt.Sprintf("%d invalid statements", len(es)))
+	}
+	return strings.Join(out, "\n")
+}
+
+// newParseError creates a parseError for an error that occurred while
+// ungronning the provided line of input. If the error relates to a
+// specific token and the line is in gron syntax (rather than JSON),
+// the line is lexed again to find out where that token is; it's only
+// done when there's an error to avoid storing positions for every token.
+// The options should be the same as were used to make the statement
+func newParseError(name string, line int, text string, err error, opts int) parseError {
+	e := parseError{
+		name: name,
+		line: line,
+		col:  1,
+		text: text,
+		msg:  err.Error(),
+	}
+
+	switch err := err.(type) {
+	case errToken:
+		e.msg = err.msg
+		e.expected = err.expected
+		if opts&optJSON > 0 {
+			break
+		}
+		l := newLexer(text)
+		l.strict = opts&optStrict > 0
+		l.lex()
+		offset := len(text)
+		if err.index < len(l.offsets) {
+			offset = l.offsets[err.index]
+		}
+		e.col = utf8.RuneCountInString(text[:offset]) + 1
+		if e.expected == "" {
+			e.expected = l.expected
+		}
+
+	case *json.SyntaxError:
+		// The offset is just after the byte that caused the error
+		offset := int(err.Offset) - 1
+		if offset < 0 {
+			offset = 0
+		}
+		if offset > len(text) {
+			offset = len(text)
+		}
+		e.col = utf8.RuneCountInString(text[:offset]) + 1
+	}
+
+	return e
+}
+
 // A lexer holds the state for lexing statements
 type lexer struct {
 	text       string  // The raw input text
@@ -42,7 +168,10 @@ type lexer struct {
 	cur        rune    // The rune at the current position
 	prev       rune    // The rune at the previous position
 	tokens     []token // The tokens that have been emitted
+	offsets    []int   // The starting position of each emitted token
 	tokenStart int     // The starting position of the current token
+	expected   string  // What was expected when an error token was emitted
+	strict     bool    // Whether to reject anything not allowed by the grammar
 }
 
 // newLexer returns a new lexer for the provided input string
@@ -52,6 +181,7 @@ func newLexer(text string) *lexer {
 		pos:        0,
 		tokenStart: 0,
 		tokens:     make([]token, 0),
+		offsets:    make([]int, 0),
 	}
 }
 
@@ -103,11 +233,19 @@ func (l *lexer) emit(typ tokenTyp) {
 		text: l.text[l.tokenStart:l.pos],
 		typ:  typ,
 	}
+	l.offsets = append(l.offsets, l.tokenStart)
 	l.tokenStart = l.pos
 
 	l.tokens = append(l.tokens, t)
 }
 
+// emitError emits an error token and records what the
+// lexer expected to find at the current position
+func (l *lexer) emitError(expected string) {
+	l.expected = expected
+	l.emit(typError)
+}
+
 // accept moves the pointer if the next rune is in
 // the set of valid runes
 func (l *lexer) accept(valid string) bool {
@@ -126,6 +264,18 @@ func (l *lexer) acceptRun(valid string) {
 	l.backup()
 }
 
+// acceptExact accepts the runes in word, in order. It stops at
+// the first rune that doesn't match and returns false
+func (l *lexer) acceptExact(word string) bool {
+	for _, r := range word {
+		if l.next() != r {
+			l.backup()
+			return false
+		}
+	}
+	return true
+}
+
 // a runeCheck is a function that determines if a rune is valid
 // or not so that we can do complex checks against runes
 type runeCheck func(rune) bool
@@ -183,6 +333,79 @@ func (l *lexer) acceptUntilUnescaped(delims string) {
 	}
 }
 
+// The sets of runes used when lexing numbers
+const (
+	digits    = "0123456789"
+	hexDigits = "0123456789abcdefABCDEF"
+)
+
+// acceptStrictString accepts a quoted string that's valid according
+// to the JSON grammar. If the string is invalid it returns false and
+// leaves the lexer at the offending rune, along with a description
+// of what was expected there instead
+func (l *lexer) acceptStrictString() (bool, string) {
+	if !l.accept(`"`) {
+		return false, "'\"'"
+	}
+
+	for {
+		r := l.next()
+		switch {
+		case r == '"':
+			return true, ""
+
+		case r == '\\':
+			if l.accept(`"\/bfnrt`) {
+				continue
+			}
+			if !l.accept("u") {
+				return false, "a valid escape sequence"
+			}
+			for i := 0; i < 4; i++ {
+				if !l.accept(hexDigits) {
+					return false, "a hex digit"
+				}
+			}
+
+		case r == utf8.RuneError && l.width == 0:
+			return false, "a closing '\"'"
+
+		case r == utf8.RuneError, r < 0x20:
+			l.backup()
+			return false, "valid UTF-8 with no unescaped control characters"
+		}
+	}
+}
+
+// acceptStrictNumber accepts a number that's valid according to the
+// JSON grammar; i.e. an optional minus sign, an integer part with no
+// leading zeros, an optional fraction and an optional exponent
+func (l *lexer) acceptStrictNumber() bool {
+	l.accept("-")
+	if !l.accept("0") {
+		if !l.accept("123456789") {
+			return false
+		}
+		l.acceptRun(digits)
+	}
+
+	if l.accept(".") {
+		if !l.accept(digits) {
+			return false
+		}
+		l.acceptRun(digits)
+	}
+
+	if l.accept("eE") {
+		l.accept("+-")
+		if !l.accept(digits) {
+			return false
+		}
+		l.acceptRun(digits)
+	}
+	return true
+}
+
 // a lexFn accepts a lexer, performs some action on it and
 // then returns an appropriate lexFn for the next stage
 type lexFn func(*lexer) lexFn
@@ -192,12 +415,21 @@ type lexFn func(*lexer) lexFn
 func lexStatement(l *lexer) lexFn {
 	r := l.peek()
 
+	// The grammar says every path starts with a bare word
+	if l.strict && len(l.tokens) == 0 && r != '-' && r != utf8.RuneError && !validFirstRune(r) {
+		l.emitError("an identifier")
+		return nil
+	}
+
 	switch {
 	case r == '.' || validFirstRune(r):
 		return lexBareWord
 	case r == '[':
 		return lexBraces
 	case r == ' ', r == '=':
+		if l.strict {
+			return lexStrictValue
+		}
 		return lexValue
 	case r == '-':
 		// grep -A etc can add '--' lines to output
@@ -205,9 +437,14 @@ func lexStatement(l *lexer) lexFn {
 		// anything with them
 		return lexIgnore
 	case r == utf8.RuneError:
+		// In strict mode a path must be followed by a value,
+		// but a completely empty line is still fine
+		if l.strict && len(l.tokens) > 0 {
+			l.emitError("'='")
+		}
 		return nil
 	default:
-		l.emit(typError)
+		l.emitError("a key, '[' or '='")
 		return nil
 	}
 
@@ -218,10 +455,15 @@ func lexStatement(l *lexer) lexFn {
 func lexBareWord(l *lexer) lexFn {
 	if l.accept(".") {
 		l.emit(typDot)
+	} else if l.strict && len(l.tokens) > 0 {
+		// The grammar only allows a bare word after
+		// the first one if there's a dot before it
+		l.emitError("'.', '[' or '='")
+		return nil
 	}
 
 	if !l.acceptFunc(validFirstRune) {
-		l.emit(typError)
+		l.emitError("an identifier")
 		return nil
 	}
 	l.acceptRunFunc(validSecondaryRune)
@@ -236,12 +478,14 @@ func lexBraces(l *lexer) lexFn {
 	l.emit(typLBrace)
 
 	switch {
-	case unicode.IsNumber(l.peek()):
+	case l.strict && strings.ContainsRune(digits, l.peek()):
+		return lexNumericKey
+	case !l.strict && unicode.IsNumber(l.peek()):
 		return lexNumericKey
 	case l.peek() == '"':
 		return lexQuotedKey
 	default:
-		l.emit(typError)
+		l.emitError("a numeric or quoted key")
 		return nil
 	}
 }
@@ -251,13 +495,17 @@ func lexNumericKey(l *lexer) lexFn {
 	l.accept("[")
 	l.ignore()
 
-	l.acceptRunFunc(unicode.IsNumber)
+	if l.strict {
+		l.acceptRun(digits)
+	} else {
+		l.acceptRunFunc(unicode.IsNumber)
+	}
 	l.emit(typNumericKey)
 
 	if l.accept("]") {
 		l.emit(typRBrace)
 	} else {
-		l.emit(typError)
+		l.emitError("']'")
 		return nil
 	}
 	l.ignore()
@@ -269,16 +517,23 @@ func lexQuotedKey(l *lexer) lexFn {
 	l.accept("[")
 	l.ignore()
 
-	l.accept(`"`)
-
-	l.acceptUntilUnescaped(`"`)
-	l.accept(`"`)
+	if l.strict {
+		if ok, expected := l.acceptStrictString(); !ok {
+			l.ignore()
+			l.emitError(expected)
+			return nil
+		}
+	} else {
+		l.accept(`"`)
+		l.acceptUntilUnescaped(`"`)
+		l.accept(`"`)
+	}
 	l.emit(typQuotedKey)
 
 	if l.accept("]") {
 		l.emit(typRBrace)
 	} else {
-		l.emit(typError)
+		l.emitError("']'")
 		return nil
 	}
 	l.ignore()
@@ -336,6 +591,7 @@ func lexValue(l *lexer) lexFn {
 
 	if l.accept(";") {
 		l.emit(typSemi)
+		lexComment(l)
 	}
 
 	// The value should always be the last thing
@@ -343,9 +599,103 @@ func lexValue(l *lexer) lexFn {
 	return nil
 }
 
+// lexComment lexes a comment after the end of a statement, if there is one
+func lexComment(l *lexer) {
+	if !strings.HasPrefix(l.text[l.pos:], " //") {
+		return
+	}
+	l.accept(" ")
+	l.ignore()
+	l.acceptRunFunc(func(r rune) bool {
+		return r != utf8.RuneError
+	})
+	l.emit(typComment)
+}
+
+// lexStrictValue is like lexValue, but only accepts values and
+// statement terminators that are exactly as described by the grammar;
+// anything else results in an error token at the offending position
+func lexStrictValue(l *lexer) lexFn {
+	l.acceptRun(" ")
+	l.ignore()
+
+	if !l.accept("=") {
+		l.emitError("'='")
+		return nil
+	}
+	l.emit(typEquals)
+	l.acceptRun(" ")
+	l.ignore()
+
+	ok := true
+	expected := "a string, number, true, false, null, [] or {}"
+	var typ tokenTyp
+
+	switch l.peek() {
+	case '"':
+		ok, expected = l.acceptStrictString()
+		typ = typString
+	case 't':
+		ok = l.acceptExact("true")
+		expected = "`true`"
+		typ = typTrue
+	case 'f':
+		ok = l.acceptExact("false")
+		expected = "`false`"
+		typ = typFalse
+	case 'n':
+		ok = l.acceptExact("null")
+		expected = "`null`"
+		typ = typNull
+	case '[':
+		ok = l.acceptExact("[]")
+		expected = "`[]`"
+		typ = typEmptyArray
+	case '{':
+		ok = l.acceptExact("{}")
+		expected = "`{}`"
+		typ = typEmptyObject
+	default:
+		ok = l.acceptStrictNumber()
+		expected = "a number"
+		typ = typNumber
+	}
+
+	if !ok {
+		l.ignore()
+		l.emitError(expected)
+		return nil
+	}
+	l.emit(typ)
+
+	if !l.accept(";") {
+		l.ignore()
+		l.emitError("';'")
+		return nil
+	}
+	l.emit(typSemi)
+
+	// Nothing but a comment is allowed after the semicolon
+	lexComment(l)
+	if l.peek() != utf8.RuneError {
+		l.emitError("the end of the statement")
+	}
+	return nil
+}
+
 // lexIgnore accepts runes until the end of the input
 // and emits them as a typIgnored token
 func lexIgnore(l *lexer) lexFn {
+	if l.strict {
+		if !l.acceptExact("--") || l.peek() != utf8.RuneError {
+			l.ignore()
+			l.emitError("a statement or '--'")
+			return nil
+		}
+		l.emit(typIgnored)
+		return nil
+	}
+
 	l.acceptRunFunc(func(r rune) bool {
 		return r != utf8.RuneError
 	})
@@ -353,6 +703,33 @@ func lexIgnore(l *lexer) lexFn {
 	return nil
 }
 
+// errToken is an error type to represent a problem with a
+// specific token in a statement. The index is relative to the
+// start of the slice of tokens that was passed to ungronTokens
+type errToken struct {
+	index    int
+	msg      string
+	expected string
+}
+
+func (e errToken) Error() string {
+	if e.expected == "" {
+		return e.msg
+	}
+	return fmt.Sprintf("%s, expected %s", e.msg, e.expected)
+}
+
+// shiftErr adjusts the index of an errToken by n so that it's
+// relative to the parent slice of tokens. Other errors are
+// returned unchanged
+func shiftErr(err error, n int) error {
+	if e, ok := err.(errToken); ok {
+		e.index += n
+		return e
+	}
+	return err
+}
+
 // ungronTokens turns a slice of tokens into an actual datastructure
 func ungronTokens(ts []token) (interface{}, error) {
 	if len(ts) == 0 {
@@ -364,14 +741,14 @@ func ungronTokens(ts []token) (interface{}, error) {
 	}
 
 	if ts[len(ts)-1].typ == typError {
-		return nil, errors.New("invalid statement")
+		return nil, errToken{index: len(ts) - 1, msg: "invalid statement"}
 	}
 
 	// The last token should be typSemi so we need to check
 	// the second to last token is a value rather than the
 	// last one
 	if len(ts) > 1 && !ts[len(ts)-2].isValue() {
-		return nil, errors.New("statement has no value")
+		return nil, errToken{index: len(ts) - 2, msg: "statement has no value", expected: "a value"}
 	}
 
 	t := ts[0]
@@ -380,7 +757,7 @@ func ungronTokens(ts []token) (interface{}, error) {
 		// Skip the token
 		val, err := ungronTokens(ts[1:])
 		if err != nil {
-			return nil, err
+			return nil, shiftErr(err, 1)
 		}
 		return val, nil
 
@@ -390,14 +767,17 @@ func ungronTokens(ts []token) (interface{}, error) {
 		d.UseNumber()
 		err := d.Decode(&val)
 		if err != nil {
-			return nil, fmt.Errorf("invalid value `%s`", t.text)
+			return nil, errToken{
+				msg:      fmt.Sprintf("invalid value `%s`", t.text),
+				expected: "a string, number, true, false, null, [] or {}",
+			}
 		}
 		return val, nil
 
 	case t.typ == typBare:
 		val, err := ungronTokens(ts[1:])
 		if err != nil {
-			return nil, err
+			return nil, shiftErr(err, 1)
 		}
 		out := make(map[string]interface{})
 		out[t.text] = val
@@ -406,12 +786,15 @@ func ungronTokens(ts []token) (interface{}, error) {
 	case t.typ == typQuotedKey:
 		val, err := ungronTokens(ts[1:])
 		if err != nil {
-			return nil, err
+			return nil, shiftErr(err, 1)
 		}
 		key := ""
 		err = json.Unmarshal([]byte(t.text), &key)
 		if err != nil {
-			return nil, fmt.Errorf("invalid quoted key `%s`", t.text)
+			return nil, errToken{
+				msg:      fmt.Sprintf("invalid quoted key `%s`", t.text),
+				expected: "a JSON string",
+			}
 		}
 
 		out := make(map[string]interface{})
@@ -421,12 +804,15 @@ func ungronTokens(ts []token) (interface{}, error) {
 	case t.typ == typNumericKey:
 		key, err := strconv.Atoi(t.text)
 		if err != nil {
-			return nil, fmt.Errorf("invalid integer key `%s`", t.text)
+			return nil, errToken{
+				msg:      fmt.Sprintf("invalid integer key `%s`", t.text),
+				expected: "a non-negative integer",
+			}
 		}
 
 		val, err := ungronTokens(ts[1:])
 		if err != nil {
-			return nil, err
+			return nil, shiftErr(err, 1)
 		}
 
 		// There needs to be at least key + 1 space in the array
@@ -435,7 +821,7 @@ func ungronTokens(ts []token) (interface{}, error) {
 		return out, nil
 
 	default:
-		return nil, fmt.Errorf("unexpected token `%s`", t.text)
+		return nil, errToken{msg: fmt.Sprintf("unexpected token `%s`", t.text)}
 	}
 }
 
@@ -514,3 +900,168 @@ func recursiveSliceMerge(a, b []interface{}) ([]interface{}, error) {
 	}
 	return out, nil
 }
+
+// An ungronReader reads statements from an input for ungronning,
+// keeping track of where each one came from so that any errors can
+// be reported with a line number and an excerpt of the input. It's
+// used a bit like a bufio.Scanner:
+//
+//	u := newUngronReader(r, opts)
+//	for u.scan() {
+//		// do something with u.stmt
+//	}
+//	if u.err != nil { ... }
+type ungronReader struct {
+	sc    *lineScanner
+	maker statementmaker
+	opts  int
+	gap   int                     // The most missing indexes an array may have; see sparseArrays
+	name  string                  // The name of the input for error messages
+	where func(int) (string, int) // Where a line came from when there's more than one input
+	stmt  statement               // The most recently read statement
+	line  int                     // The line number of the most recent statement
+	text  string                  // The raw text of the most recent statement
+	err   error                   // The error that stopped the reader, if any
+	code  int                     // The exit code to go with err
+	errs  parseErrors             // Errors collected with optKeepGoing
+}
+
+// newUngronReader returns an ungronReader for the input r. Statements
+// are parsed in JSON form with optJSON, or in strict mode with optStrict
+func newUngronReader(r io.Reader, opts int, c actionConfig) *ungronReader {
+	sc := newLineScanner(r, c.maxLineSize)
+
+	var maker statementmaker
+	switch {
+	case opts&optJSON > 0:
+		maker = statementFromJSONSpec
+	case opts&optStrict > 0:
+		maker = statementFromStringStrictMaker
+	default:
+		maker = statementFromStringMaker
+	}
+
+	u := &ungronReader{
+		sc:    sc,
+		maker: maker,
+		opts:  opts,
+		gap:   c.maxArrayGap,
+		name:  inputName(r),
+	}
+	if c, ok := r.(*concatReader); ok {
+		u.where = c.locate
+	}
+	return u
+}
+
+// scan reads the next statement from the input, returning false
+// when there are no more statements or an error stopped the reader
+func (u *ungronReader) scan() bool {
+	if u.err != nil {
+		return false
+	}
+
+	for u.sc.Scan() {
+		u.line++
+		s, err := u.maker(u.sc.Text())
+		if err != nil {
+			if err := u.report(u.line, u.sc.Text(), err); err != nil {
+				u.fail(exitParseStatements, err)
+				return false
+			}
+			continue
+		}
+		u.stmt = s
+		u.text = u.sc.Text()
+		return true
+	}
+
+	if err := u.sc.Err(); err != nil {
+		u.fail(exitReadInput, fmt.Errorf("failed to read input statements: %s", err))
+	}
+	return false
+}
+
+// fail stops the reader with an exit code and error
+func (u *ungronReader) fail(code int, err error) {
+	u.code = code
+	u.err = err
+}
+
+// report either collects a parse error to be returned
+// later, or returns it straight away
+func (u *ungronReader) report(line int, text string, err error) error {
+	name := u.name
+	if u.where != nil {
+		name, line = u.where(line)
+	}
+	pe := newParseError(name, line, text, err, u.opts)
+	if u.opts&optKeepGoing > 0 {
+		u.errs = append(u.errs, pe)
+		return nil
+	}
+	return pe
+}
+
+// finish returns the exit code and error that stopped the reader, or
+// any errors that were collected along the way with optKeepGoing
+func (u *ungronReader) finish() (int, error) {
+	if u.err != nil {
+		return u.code, u.err
+	}
+	if len(u.errs) > 0 {
+		return exitParseStatements, u.errs
+	}
+	return exitOK, nil
+}
+
+// An ungronBatch is a list of statements that are ungronned together,
+// along with the line number and text of each one for error reporting
+type ungronBatch struct {
+	ss    statements
+	lines []int
+	texts []string
+
+	// The number of tokens that were removed from the start
+	// of each statement before it was added to the batch
+	trimmed int
+}
+
+// add appends a statement to the batch
+func (b *ungronBatch) add(s statement, line int, text string) {
+	b.ss.add(s)
+	b.lines = append(b.lines, line)
+	b.texts = append(b.texts, text)
+}
+
+// ungron turns the batch of statements into a single merged
+// datastructure, reporting any errors via the ungronReader
+func (u *ungronReader) ungron(b *ungronBatch) (interface{}, error) {
+	reportStatement := func(i int, err error) error {
+		return u.report(b.lines[i], b.texts[i], shiftErr(errors.Cause(err), b.trimmed))
+	}
+
+	// deal with any arrays that have missing indexes
+	err := b.ss.sparseArrays(u.opts, u.gap, reportStatement)
+	if err != nil {
+		return nil, err
+	}
+
+	// comments can't be ungronned, but they say which
+	// values need to be turned back into strings of JSON
+	embedded := b.ss.stripComments()
+
+	// turn the statements into a single merged interface{} type
+	merged, err := b.ss.toInterfaceFunc(reportStatement)
+	if err == nil {
+		reembed(merged, embedded)
+	}
+
+	// If errors have been collected with optKeepGoing there
+	// might not be anything left to ungron, but the collected
+	// errors say why better than any error from here would
+	if err != nil && len(u.errs) > 0 {
+		return nil, nil
+	}
+	return merged, err
+}
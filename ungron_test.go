@@ -290,3 +290,128 @@ func TestMerge(t *testing.T) {
 	}
 
 }
+
+func TestParseErrorPosition(t *testing.T) {
+	cases := []struct {
+		in       string
+		wantCol  int
+		wantMsg  string
+		expected string
+	}{
+		{`json.x = tru;`, 10, "invalid value `tru`", "a string, number, true, false, null, [] or {}"},
+		{`json.b[ = 2;`, 8, "invalid statement", "a numeric or quoted key"},
+		{`json[1 = 1;`, 7, "invalid statement", "']'"},
+		{`json.[2] = 1;`, 6, "invalid statement", "an identifier"},
+		{`wat!`, 4, "invalid statement", "a key, '[' or '='"},
+		{`json.héllo = tru;`, 14, "invalid value `tru`", "a string, number, true, false, null, [] or {}"},
+	}
+
+	for _, c := range cases {
+		_, err := ungronTokens(statementFromString(c.in))
+		if err == nil {
+			t.Fatalf("want non-nil error for %s; have nil", c.in)
+		}
+
+		have := newParseError("test.gron", 3, c.in, err, 0)
+		if have.line != 3 {
+			t.Errorf("want line 3 for %s; have %d", c.in, have.line)
+		}
+		if have.col != c.wantCol {
+			t.Errorf("want col %d for %s; have %d", c.wantCol, c.in, have.col)
+		}
+		if have.msg != c.wantMsg {
+			t.Errorf("want msg %q for %s; have %q", c.wantMsg, c.in, have.msg)
+		}
+		if have.expected != c.expected {
+			t.Errorf("want expected %q for %s; have %q", c.expected, c.in, have.expected)
+		}
+	}
+}
+
+func TestParseErrorExcerpt(t *testing.T) {
+	e := parseError{
+		name: "test.gron",
+		line: 12,
+		col:  10,
+		text: "json.x = tru;",
+		msg:  "invalid value `tru`",
+	}
+
+	want := "test.gron:12:10: invalid value `tru`\n" +
+		"    json.x = tru;\n" +
+		"             ^"
+
+	if e.Error() != want {
+		t.Errorf("want:\n%s\nhave:\n%s", want, e.Error())
+	}
+}
+
+func TestLexStrict(t *testing.T) {
+	valid := []string{
+		`json = {};`,
+		`json.a = true;`,
+		`json.a  =  false;`,
+		`json.a = null;`,
+		`json.a = [];`,
+		`json["a b"][0] = "\"\\\/\b\f\n\r\té";`,
+		`json.a = 0;`,
+		`json.a = -1.5e+10;`,
+		`json.a = 12E3;`,
+		`json.a = 1; // duplicate key, 1 of 2`,
+		`--`,
+		``,
+	}
+
+	for _, in := range valid {
+		l := newLexer(in)
+		l.strict = true
+		ts := l.lex()
+		for _, tok := range ts {
+			if tok.typ == typError {
+				t.Errorf("want no error token for `%s`; have %#v (expected %s)", in, ts, l.expected)
+			}
+		}
+	}
+
+	invalid := []struct {
+		in       string
+		expected string
+	}{
+		{`json.a = truuue;`, "`true`"},
+		{`json.a = trueee;`, "';'"},
+		{`json.a = tru;`, "`true`"},
+		{`json.a = nul;`, "`null`"},
+		{`json.a = [ ];`, "`[]`"},
+		{`json.a = 01;`, "';'"},
+		{`json.a = 1.;`, "a number"},
+		{`json.a = .5;`, "a number"},
+		{`json.a = 1e;`, "a number"},
+		{`json.a = 1`, "';'"},
+		{`json.a = 1 ;`, "';'"},
+		{`json.a = 1; x`, "the end of the statement"},
+		{`json.a = 1; /`, "the end of the statement"},
+		{`json.a = "a\qb";`, "a valid escape sequence"},
+		{`json.a = "\u12G4";`, "a hex digit"},
+		{"json.a = \"a\tb\";", "valid UTF-8 with no unescaped control characters"},
+		{`json.a = "abc`, "a closing '\"'"},
+		{`json["a\x"] = 1;`, "a valid escape sequence"},
+		{`json[١] = 1;`, "a numeric or quoted key"},
+		{`[0] = 1;`, "an identifier"},
+		{`json.x[0]abc = 1;`, "'.', '[' or '='"},
+		{`json.a`, "'='"},
+		{`-- x`, "a statement or '--'"},
+	}
+
+	for _, c := range invalid {
+		l := newLexer(c.in)
+		l.strict = true
+		ts := l.lex()
+		if len(ts) == 0 || ts[len(ts)-1].typ != typError {
+			t.Errorf("want error token for `%s`; have %#v", c.in, ts)
+			continue
+		}
+		if l.expected != c.expected {
+			t.Errorf("want expected %s for `%s`; have %s", c.expected, c.in, l.expected)
+		}
+	}
+}
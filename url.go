@@ -2,13 +2,14 @@ package main
 
 import (
 	"bufio"
-	"crypto/tls"
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	neturl "net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -66,32 +67,369 @@ func configureProxy(url string, proxy string, noProxy string) func(*http.Request
 	return http.ProxyURL(proxyURL)
 }
 
-func getURL(url string, insecure bool, proxyURL string, noProxy string) (io.Reader, error) {
+// A requestConfig holds the options for fetching URLs
+type requestConfig struct {
+	insecure bool
+	proxyURL string
+	noProxy  string
+	method   string      // The HTTP method; GET, or POST if there's a body
+	header   http.Header // Extra headers, which override the defaults
+	body     []byte      // The request body, if any
+	user     string      // 'user:password' for basic auth
+	token    string      // A bearer token
+	netrc    netrc       // Credentials to look up by host
+
+	timeout      time.Duration // How long a request may take; 0 for no limit
+	retries      int           // How many times to retry a failed request
+	retryWait    time.Duration // How long to wait before the first retry; it doubles each time
+	retryAll     bool          // Retry methods that aren't idempotent too, like POST
+	ignoreStatus bool          // Use the body of a non-2xx response instead of failing
+
+	cache *httpCache // Where to cache responses; nil for no cache
+
+	caCert     string            // A PEM file of CAs to use instead of the system's
+	cert       string            // A PEM file with a client certificate
+	key        string            // A PEM file with the client certificate's key, if it's not in cert
+	tlsMin     uint16            // The minimum TLS version
+	serverName string            // The name to send for SNI and verify, instead of the URL's host
+	resolve    map[string]string // Addresses to connect to instead of looking up host:port
+}
+
+// hasCredentials says if any credentials or headers were given as options
+func (c requestConfig) hasCredentials() bool {
+	return c.user != "" || c.token != "" || len(c.header) > 0
+}
+
+// withoutCredentials returns a copy of the config without the credentials
+// and headers given as options, for requests to hosts they weren't meant
+// for. Credentials from .netrc are still used because they're per host
+func (c requestConfig) withoutCredentials() requestConfig {
+	c.user = ""
+	c.token = ""
+	c.header = nil
+	return c
+}
+
+// maxRetryWait is the longest that's waited before retrying a request,
+// even if the server asks for longer with a Retry-After header
+var maxRetryWait = time.Minute
+
+// A statusError is returned for responses with a non-2xx status code.
+// It includes the start of the body, which usually says what went wrong
+type statusError struct {
+	method string
+	url    string
+	status string
+	body   string
+}
+
+func (e statusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %s", e.method, e.url, e.status)
+	if e.body != "" {
+		msg += ": " + e.body
+	}
+	return msg + " (use --ignore-status to gron the response anyway)"
+}
+
+// parseHeaders parses headers in the 'Name: value' form used by curl.
+// Environment variables in the values are expanded so that tokens
+// don't have to appear on the command line; e.g. 'Authorization: Bearer $TOKEN'
+func parseHeaders(headers []string) (http.Header, error) {
+	h := make(http.Header)
+	for _, raw := range headers {
+		name, value, ok := strings.Cut(raw, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q; must be in the form 'Name: value'", raw)
+		}
+		h.Add(name, os.ExpandEnv(strings.TrimSpace(value)))
+	}
+	return h, nil
+}
+
+// readData reads a request body in the form used by curl's --data;
+// either the data itself, or @ followed by a filename (or - for stdin)
+func readData(arg string) ([]byte, error) {
+	if strings.HasPrefix(arg, "@") {
+		return readDataFile(arg[1:])
+	}
+	return []byte(arg), nil
+}
+
+// readDataFile reads a request body from a file, or from stdin if
+// the filename is -
+func readDataFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// readToken reads a bearer token from an environment variable
+// or a file; whichever is given
+func readToken(envName, filename string) (string, error) {
+	if envName != "" {
+		token, ok := os.LookupEnv(envName)
+		if !ok || token == "" {
+			return "", fmt.Errorf("environment variable %s is not set", envName)
+		}
+		return token, nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", filename)
+	}
+	return token, nil
+}
+
+// newRequest makes the request for a URL. The default headers can be
+// overridden by the headers in the config, and the body is sent as
+// JSON unless a Content-Type header says otherwise
+func newRequest(url string, c requestConfig) (*http.Request, error) {
+	method := c.method
+	if method == "" {
+		method = "GET"
+		if c.body != nil {
+			method = "POST"
+		}
+	}
+
+	var body io.Reader
+	if c.body != nil {
+		body = bytes.NewReader(c.body)
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("gron/%s", gronVersion))
+	req.Header.Set("Accept", "application/json")
+	if c.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Credentials given as options win over any in .netrc,
+	// and an Authorization header wins over everything
+	switch {
+	case c.user != "":
+		user, pass, _ := strings.Cut(c.user, ":")
+		req.SetBasicAuth(user, pass)
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	default:
+		if m, ok := c.netrc.lookup(req.URL.Hostname()); ok && m.login != "" {
+			req.SetBasicAuth(m.login, m.password)
+		}
+	}
+
+	for name, values := range c.header {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	return req, nil
+}
+
+func getURL(url string, c requestConfig) (io.Reader, error) {
+	resp, err := fetchURL(url, c)
+	if err != nil {
+		return nil, err
+	}
+	return resp.body, nil
+}
+
+// A response is the response for a URL, with its decompressed
+// body and how long each part of fetching it took
+type response struct {
+	*http.Response
+	body   io.Reader
+	timing *requestTiming
+}
+
+// fetchURL is like getURL, but returns the whole response so
+// that the headers can be looked at. Requests that fail, or get
+// a 429 or 5xx response, are retried with exponential backoff
+func fetchURL(url string, c requestConfig) (*response, error) {
+	// A cached response is used as it is while it's fresh. After
+	// that the server is asked if it's changed, using its ETag or
+	// Last-Modified header
+	var cacheKey string
+	var cached *cacheEntry
+	if c.cache != nil {
+		req, err := newRequest(url, c)
+		if err != nil {
+			return nil, err
+		}
+		if cacheableMethod(req.Method) {
+			cacheKey = c.cache.key(req, c.body)
+			cached = c.cache.load(cacheKey)
+		}
+		if cached != nil {
+			if cached.fresh(time.Now(), c.cache.ttl) {
+				return cached.response(req), nil
+			}
+			c.header = cached.conditional(c.header)
+		}
+	}
+
+	tlsConf, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		TLSClientConfig: tlsConf,
+		DialContext:     dialContext(c.resolve),
 	}
 	// Set proxy if defined.
-	proxy := configureProxy(url, proxyURL, noProxy)
+	proxy := configureProxy(url, c.proxyURL, c.noProxy)
 	if proxy != nil {
 		tr.Proxy = proxy
 	}
 	client := http.Client{
 		Transport: tr,
-		Timeout:   20 * time.Second,
+		Timeout:   c.timeout,
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	var resp *http.Response
+	var req *http.Request
+	var timing *requestTiming
+	for attempt := 0; ; attempt++ {
+		var err error
+		req, err = newRequest(url, c)
+		if err != nil {
+			return nil, err
+		}
+		timing = &requestTiming{}
+		req = timing.trace(req)
+
+		// Setting Accept-Encoding stops the transport decompressing gzip
+		// by itself, so every Content-Encoding is dealt with in one place
+		req.Header.Set("Accept-Encoding", "gzip, zstd")
+
+		resp, err = client.Do(req)
+
+		retry := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !c.retryAll && !idempotent(req.Method) {
+			// The first request might have done something even
+			// though it failed, and doing it again could repeat it
+			retry = false
+		}
+		if !retry || attempt >= c.retries {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		wait := c.retryWait << attempt
+		reason := fmt.Sprint(err)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = after
+			}
+			reason = fmt.Sprintf("%s %s: %s", req.Method, url, resp.Status)
+			resp.Body.Close()
+		}
+		if wait > maxRetryWait || wait < 0 {
+			wait = maxRetryWait
+		}
+		warn("%s; retrying in %s (%d of %d)", reason, wait, attempt+1, c.retries)
+		time.Sleep(wait)
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		cached.revalidated(resp.Header, time.Now())
+		if err := c.cache.store(cacheKey, cached); err != nil {
+			warn("failed to update cached response for %s: %s", url, err)
+		}
+		r := cached.response(req)
+		r.timing = timing
+		return r, nil
+	}
+
+	body, err := decodeContent(resp.Header.Get("Content-Encoding"), bufio.NewReader(resp.Body))
 	if err != nil {
+		resp.Body.Close()
 		return nil, err
 	}
-	req.Header.Set("User-Agent", fmt.Sprintf("gron/%s", gronVersion))
-	req.Header.Set("Accept", "application/json")
 
-	resp, err := client.Do(req)
+	if !c.ignoreStatus && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		start, _ := io.ReadAll(io.LimitReader(body, 200))
+		resp.Body.Close()
+		return nil, statusError{
+			method: req.Method,
+			url:    url,
+			status: resp.Status,
+			body:   strings.Join(strings.Fields(string(start)), " "),
+		}
+	}
+
+	if cacheKey != "" && storable(resp) {
+		data, err := io.ReadAll(body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.cache.store(cacheKey, newCacheEntry(resp, data, time.Now())); err != nil {
+			warn("failed to cache response for %s: %s", url, err)
+		}
+		body = bytes.NewReader(data)
+	}
+	return &response{resp, body, timing}, nil
+}
+
+// idempotent returns true for the methods that can be
+// repeated without changing the result of the first request
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
 
+// retryAfter parses a Retry-After header, which is either
+// a number of seconds or an HTTP date
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(header)
 	if err != nil {
-		return nil, err
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// decodeContent decompresses a response body according to its
+// Content-Encoding header
+func decodeContent(encoding string, body io.Reader) (io.Reader, error) {
+	if encoding == "" || strings.EqualFold(encoding, "identity") {
+		return body, nil
 	}
 
-	return bufio.NewReader(resp.Body), err
+	c, ok := compressionByName(encoding)
+	if !ok {
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	r, err := c.newReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s response: %s", c.name, err)
+	}
+	return r, nil
 }
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidURL(t *testing.T) {
@@ -118,3 +123,242 @@ func TestConfigureProxyHttps(t *testing.T) {
 		os.Unsetenv("no_proxy")
 	}
 }
+
+func TestParseHeaders(t *testing.T) {
+	os.Setenv("GRON_TEST_TOKEN", "secret")
+	defer os.Unsetenv("GRON_TEST_TOKEN")
+
+	h, err := parseHeaders([]string{
+		"Authorization: Bearer $GRON_TEST_TOKEN",
+		"X-Empty:",
+		"X-Multi: a",
+		"X-Multi: b",
+	})
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	if have := h.Get("Authorization"); have != "Bearer secret" {
+		t.Errorf("want expanded Authorization header; have %q", have)
+	}
+	if have := h.Values("X-Multi"); len(have) != 2 {
+		t.Errorf("want 2 X-Multi values; have %v", have)
+	}
+	if _, ok := h["X-Empty"]; !ok {
+		t.Errorf("want empty X-Empty header to be set")
+	}
+
+	for _, invalid := range []string{"no colon", ": no name", "Bad Name: x"} {
+		if _, err := parseHeaders([]string{invalid}); err == nil {
+			t.Errorf("want error for header %q; have nil", invalid)
+		}
+	}
+}
+
+func TestGetURLRequest(t *testing.T) {
+	var method, contentType, custom string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		custom = r.Header.Get("X-Custom")
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	c := requestConfig{
+		proxyURL: undefinedProxy,
+		noProxy:  undefinedProxy,
+		header:   http.Header{"X-Custom": {"yes"}},
+		body:     []byte(`{"q": 1}`),
+	}
+	r, err := getURL(srv.URL, c)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	io.ReadAll(r)
+
+	if method != "POST" {
+		t.Errorf("want POST for a request with a body; have %s", method)
+	}
+	if contentType != "application/json" {
+		t.Errorf("want application/json Content-Type; have %s", contentType)
+	}
+	if custom != "yes" {
+		t.Errorf("want X-Custom header; have %q", custom)
+	}
+	if string(body) != `{"q": 1}` {
+		t.Errorf("want request body to be sent; have %q", body)
+	}
+
+	c.method = "put"
+	c.header.Set("Content-Type", "text/plain")
+	r, err = getURL(srv.URL, c)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	io.ReadAll(r)
+	if method != "PUT" || contentType != "text/plain" {
+		t.Errorf("want PUT with text/plain; have %s with %s", method, contentType)
+	}
+}
+
+func TestNewRequestAuth(t *testing.T) {
+	n := netrc{{name: "api.example.com", login: "tom", password: "s3cret"}}
+
+	cases := []struct {
+		c    requestConfig
+		want string
+	}{
+		{requestConfig{user: "sam:pass", netrc: n}, "Basic c2FtOnBhc3M="},
+		{requestConfig{token: "abc", netrc: n}, "Bearer abc"},
+		{requestConfig{netrc: n}, "Basic dG9tOnMzY3JldA=="},
+		{requestConfig{token: "abc", header: http.Header{"Authorization": {"Custom x"}}}, "Custom x"},
+	}
+
+	for _, c := range cases {
+		req, err := newRequest("https://api.example.com/users", c.c)
+		if err != nil {
+			t.Fatalf("want nil error; have %s", err)
+		}
+		if have := req.Header.Get("Authorization"); have != c.want {
+			t.Errorf("want Authorization %q; have %q", c.want, have)
+		}
+	}
+
+	// No credentials for other hosts
+	req, _ := newRequest("https://other.example.com/", requestConfig{netrc: n})
+	if have := req.Header.Get("Authorization"); have != "" {
+		t.Errorf("want no Authorization header; have %q", have)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"Wed, 01 Jan 2020 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 01 Jan 2020 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"-1", 0, false},
+	}
+
+	for _, c := range cases {
+		have, ok := retryAfter(c.header, now)
+		if have != c.want || ok != c.ok {
+			t.Errorf("want %s, %t for retryAfter(%q); have %s, %t", c.want, c.ok, c.header, have, ok)
+		}
+	}
+}
+
+func TestFetchURLRetries(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":   "no such thing"}`))
+		case requests < 3:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("<html>busy</html>"))
+		default:
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := requestConfig{
+		proxyURL:  undefinedProxy,
+		noProxy:   undefinedProxy,
+		retries:   1,
+		retryWait: time.Millisecond,
+	}
+
+	// Not enough retries
+	_, err := getURL(srv.URL, c)
+	if err == nil || !strings.Contains(err.Error(), "503 Service Unavailable: <html>busy</html>") {
+		t.Errorf("want 503 error with the start of the body; have %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("want 2 requests; have %d", requests)
+	}
+
+	// Enough retries
+	requests = 0
+	c.retries = 2
+	r, err := getURL(srv.URL, c)
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+	if b, _ := io.ReadAll(r); string(b) != `{"ok": true}` {
+		t.Errorf("want the body of the successful response; have %q", b)
+	}
+
+	// A 404 isn't retried, but its body can be used anyway
+	requests = 0
+	_, err = getURL(srv.URL+"/missing", c)
+	if err == nil || !strings.Contains(err.Error(), `404 Not Found: {"error": "no such thing"}`) {
+		t.Errorf("want 404 error with the start of the body; have %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("want 1 request; have %d", requests)
+	}
+
+	c.ignoreStatus = true
+	r, err = getURL(srv.URL+"/missing", c)
+	if err != nil {
+		t.Fatalf("want nil error with ignoreStatus; have %s", err)
+	}
+	if b, _ := io.ReadAll(r); string(b) != `{"error":   "no such thing"}` {
+		t.Errorf("want the body of the 404 response; have %q", b)
+	}
+}
+
+func TestFetchURLRetriesIdempotent(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		method   string
+		retryAll bool
+		want     int
+	}{
+		{"GET", false, 3},
+		{"PUT", false, 3},
+		{"DELETE", false, 3},
+		{"POST", false, 1},
+		{"PATCH", false, 1},
+		{"POST", true, 3},
+	}
+
+	for _, c := range cases {
+		requests = 0
+		_, err := getURL(srv.URL, requestConfig{
+			proxyURL:  undefinedProxy,
+			noProxy:   undefinedProxy,
+			method:    c.method,
+			retries:   2,
+			retryWait: time.Millisecond,
+			retryAll:  c.retryAll,
+		})
+		if err == nil {
+			t.Errorf("want non-nil error for %s", c.method)
+		}
+		if requests != c.want {
+			t.Errorf("want %d requests for %s with retryAll %t; have %d", c.want, c.method, c.retryAll, requests)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// jsonExtensions are the file extensions that are considered to be
+// JSON-like when walking a directory and no include patterns are given
+var jsonExtensions = map[string]bool{
+	".json":    true,
+	".jsonl":   true,
+	".ndjson":  true,
+	".geojson": true,
+	".har":     true,
+}
+
+// streamExtensions are the file extensions for files that hold one JSON
+// value per line. They're gronned as a stream, like with --stream
+var streamExtensions = map[string]bool{
+	".jsonl":  true,
+	".ndjson": true,
+}
+
+// defaultIgnoreFile is the name of an ignore file that's used
+// automatically if it exists in the root of a directory being walked
+const defaultIgnoreFile = ".gronignore"
+
+// An ignoreRule is a single line from a .gitignore-style ignore file
+type ignoreRule struct {
+	segments []string // The pattern split on '/'
+	negate   bool     // The pattern started with '!'
+	dirOnly  bool     // The pattern ended with '/'
+	anchored bool     // The pattern must match from the root
+}
+
+// parseIgnoreRule parses a line from an ignore file. It returns
+// false for blank lines and comments
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var r ignoreRule
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A pattern with a slash anywhere but the end is relative
+	// to the root, otherwise it can match at any level
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return r, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// matches returns true if the rule matches the slash-separated path,
+// which is relative to the root of the directory being walked
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	if r.anchored {
+		return matchSegments(r.segments, parts)
+	}
+
+	// Unanchored patterns can match any trailing part of the path
+	for i := range parts {
+		if matchSegments(r.segments, parts[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments,
+// where a '**' segment matches zero or more path segments
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], parts[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+// ignoreRules is a list of ignore rules, where later rules
+// take precedence over earlier ones
+type ignoreRules []ignoreRule
+
+// readIgnoreFile reads the rules from a .gitignore-style file
+func readIgnoreFile(filename string) (ignoreRules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules ignoreRules
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreRule(sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules, sc.Err()
+}
+
+// ignored returns true if the path should be ignored; i.e. the last
+// rule to match it isn't a negated one
+func (rs ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range rs {
+		if r.matches(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// A walkConfig holds the options for walking directories
+type walkConfig struct {
+	include    []string // Patterns for the files to include
+	exclude    []string // Patterns for files and directories to exclude
+	ignoreFile string   // The path to an extra ignore file
+}
+
+// matchAny returns true if the base name or the slash-separated
+// relative path matches any of the glob patterns
+func matchAny(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkInputs finds all of the JSON-like files in the named directories.
+// Each file's name is its path relative to the directory it was found
+// in, so that the statements for it don't depend on where gron was run
+// from. When there's more than one name, the directory is kept at the
+// start so that files from different directories can be told apart.
+// Names that aren't directories are returned as they are
+func walkInputs(names []string, c walkConfig) ([]input, error) {
+	var extra ignoreRules
+	if c.ignoreFile != "" {
+		rules, err := readIgnoreFile(c.ignoreFile)
+		if err != nil {
+			return nil, err
+		}
+		extra = rules
+	}
+
+	var inputs []input
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil || !info.IsDir() {
+			inputs = append(inputs, input{name: name, path: name})
+			continue
+		}
+
+		rules := extra
+		if own, err := readIgnoreFile(filepath.Join(name, defaultIgnoreFile)); err == nil {
+			rules = append(own, extra...)
+		}
+
+		root := name
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == root {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if d.IsDir() {
+				if d.Name() == ".git" || matchAny(c.exclude, rel) || rules.ignored(rel, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !d.Type().IsRegular() || d.Name() == defaultIgnoreFile {
+				return nil
+			}
+			if matchAny(c.exclude, rel) || rules.ignored(rel, false) {
+				return nil
+			}
+
+			if len(c.include) > 0 {
+				if !matchAny(c.include, rel) {
+					return nil
+				}
+			} else if !jsonExtensions[strings.ToLower(path.Ext(rel))] {
+				return nil
+			}
+			stream := streamExtensions[strings.ToLower(path.Ext(rel))]
+
+			if len(names) > 1 {
+				rel = path.Join(filepath.ToSlash(root), rel)
+			}
+			inputs = append(inputs, input{name: rel, path: p, stream: stream})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Two inputs with the same name would be merged when ungronned
+	seen := make(map[string]string, len(inputs))
+	for _, in := range inputs {
+		if prev, ok := seen[in.name]; ok {
+			if prev == in.path {
+				return nil, fmt.Errorf("%s would be gronned twice", in.path)
+			}
+			return nil, fmt.Errorf("%s and %s would both be gronned as %q", prev, in.path, in.name)
+		}
+		seen[in.name] = in.path
+	}
+	return inputs, nil
+}
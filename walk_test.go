@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	var rules ignoreRules
+	for _, line := range []string{
+		"# a comment",
+		"",
+		"*.log",
+		"node_modules/",
+		"/build",
+		"docs/**/*.json",
+		"!docs/keep.json",
+	} {
+		if r, ok := parseIgnoreRule(line); ok {
+			rules = append(rules, r)
+		}
+	}
+
+	cases := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"node_modules", true, true},
+		{"a/node_modules", true, true},
+		{"node_modules", false, false},
+		{"build", true, true},
+		{"a/build", true, false},
+		{"docs/x.json", false, true},
+		{"docs/a/b/x.json", false, true},
+		{"docs/keep.json", false, false},
+		{"src/x.json", false, false},
+	}
+
+	for _, c := range cases {
+		have := rules.ignored(c.rel, c.isDir)
+		if have != c.want {
+			t.Errorf("want ignored(%q, %t) to be %t; have %t", c.rel, c.isDir, c.want, have)
+		}
+	}
+}
+
+func TestWalkInputs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a/one.json":          `{}`,
+		"a/b/two.JSON":        `{}`,
+		"a/b/skip.json":       `{}`,
+		"a/t.txt":             `txt`,
+		"c.ndjson":            `{}`,
+		"node_modules/n.json": `{}`,
+		".git/g.json":         `{}`,
+		".gronignore":         "node_modules/\n*skip*\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to make test dir: %s", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write test file: %s", err)
+		}
+	}
+
+	cases := []struct {
+		c    walkConfig
+		want []string
+	}{
+		{walkConfig{}, []string{"a/b/two.JSON", "a/one.json", "c.ndjson"}},
+		{walkConfig{exclude: []string{"b"}}, []string{"a/one.json", "c.ndjson"}},
+		{walkConfig{include: []string{"*.txt", "a/b/*"}}, []string{"a/b/two.JSON", "a/t.txt"}},
+	}
+
+	for _, c := range cases {
+		inputs, err := walkInputs([]string{dir}, c.c)
+		if err != nil {
+			t.Fatalf("want nil error; have %s", err)
+		}
+
+		have := make([]string, 0, len(inputs))
+		for _, in := range inputs {
+			have = append(have, in.name)
+			if in.path != filepath.Join(dir, filepath.FromSlash(in.name)) {
+				t.Errorf("want path for %s to be in %s; have %s", in.name, dir, in.path)
+			}
+			if in.stream != strings.HasSuffix(in.name, ".ndjson") {
+				t.Errorf("want only .ndjson files to be streamed; have %t for %s", in.stream, in.name)
+			}
+		}
+		if !reflect.DeepEqual(have, c.want) {
+			t.Errorf("want %v for %#v; have %v", c.want, c.c, have)
+		}
+	}
+}
+
+func TestWalkInputsRoots(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"d1/x.json", "d2/x.json"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to make test dir: %s", err)
+		}
+		if err := os.WriteFile(p, []byte(`{}`), 0644); err != nil {
+			t.Fatalf("failed to write test file: %s", err)
+		}
+	}
+
+	d1, d2 := filepath.Join(dir, "d1"), filepath.Join(dir, "d2")
+	inputs, err := walkInputs([]string{d1, d2}, walkConfig{})
+	if err != nil {
+		t.Fatalf("want nil error; have %s", err)
+	}
+
+	have := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		have = append(have, in.name)
+	}
+	want := []string{
+		filepath.ToSlash(filepath.Join(d1, "x.json")),
+		filepath.ToSlash(filepath.Join(d2, "x.json")),
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v; have %v", want, have)
+	}
+
+	// The same file found twice can't be told apart
+	_, err = walkInputs([]string{d1, d1 + string(filepath.Separator)}, walkConfig{})
+	if err == nil {
+		t.Errorf("want error for two inputs with the same name; have nil")
+	}
+}